@@ -3,6 +3,7 @@ package errcode
 import (
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 
@@ -98,6 +99,26 @@ func Len() int {
 	return len(errCodeDict)
 }
 
+// ErrorCodes implements errcode_if.ErrorCodeListerIf, sorted by Errno and Code
+func (ec *ErrorCode) ErrorCodes() []errcode_if.ErrorCodeIf {
+	codes := make([]*ErrorCode, 0, len(errCodeDict))
+	for _, c := range errCodeDict {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if codes[i].Errno != codes[j].Errno {
+			return codes[i].Errno < codes[j].Errno
+		}
+		return codes[i].Code < codes[j].Code
+	})
+
+	ecs := make([]errcode_if.ErrorCodeIf, len(codes))
+	for i, c := range codes {
+		ecs[i] = c
+	}
+	return ecs
+}
+
 func DumpErrorCodes() string {
 	output := fmt.Sprintf("Total:%d\n", len(errCodeDict))
 	return utils.ToPrettyString(errCodeDict) + output
@@ -208,3 +208,18 @@ func TestWithWrap(t *testing.T) {
 
 	require.Equal(t, "{\"Code\":\"ObjectExist\",\"Errno\":400,\"Message\":\"Code:ObjectExist, Errno:400\"}", fmt.Sprintf("%v", ErrObjectExist()))
 }
+
+func TestErrorCodes(t *testing.T) {
+	ecs := (&ErrorCode{}).ErrorCodes()
+	require.Len(t, ecs, Len())
+
+	codes := make(map[string]int)
+	for i, ec := range ecs {
+		codes[ec.GetCode()] = ec.GetErrno()
+		if i > 0 {
+			require.LessOrEqual(t, ecs[i-1].GetErrno(), ec.GetErrno())
+		}
+	}
+	require.Equal(t, http.StatusUnauthorized, codes["InvalidJwt"])
+	require.Equal(t, http.StatusTooManyRequests, codes["TooManyRequests"])
+}
@@ -16,3 +16,9 @@ type ErrorCodeDictionaryIf interface {
 	ToHttpStatus(int) int
 	NewRequestId() string
 }
+
+// ErrorCodeListerIf is optionally implemented by ErrorCodeDictionaryIf to list all the
+// registered error codes, e.g. for API documents
+type ErrorCodeListerIf interface {
+	ErrorCodes() []ErrorCodeIf
+}
@@ -21,15 +21,20 @@ type Graceful struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 	sigCh  chan os.Signal
+	// preStopDelay keeps services running after ctx is done
+	preStopDelay time.Duration
+	// quitTimeout limits the time of stopping services, 5s by default
+	quitTimeout time.Duration
 }
 
 func New() *Graceful {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	gc := &Graceful{
-		ctx:    ctx,
-		cancel: cancel,
-		sigCh:  make(chan os.Signal, 1),
+		ctx:         ctx,
+		cancel:      cancel,
+		sigCh:       make(chan os.Signal, 1),
+		quitTimeout: 5 * time.Second,
 	}
 
 	go gc.listenToSignal()
@@ -42,6 +47,18 @@ func (gc *Graceful) Context() context.Context {
 	return gc.ctx
 }
 
+// SetPreStopDelay waits d between the cancellation of Context() and stopping services in WaitToQuit,
+// so that load balancers can observe the failing readiness and stop sending traffic
+func (gc *Graceful) SetPreStopDelay(d time.Duration) {
+	gc.preStopDelay = d
+}
+
+// SetQuitTimeout limits the time of stopping services in WaitToQuit, it should cover the drain
+// timeouts of the services, e.g. httpx.ShutdownConfig
+func (gc *Graceful) SetQuitTimeout(d time.Duration) {
+	gc.quitTimeout = d
+}
+
 func (gc *Graceful) listenToSignal() {
 
 	defer gc.cancel()
@@ -63,7 +80,12 @@ func (gc *Graceful) listenToSignal() {
 func (gc *Graceful) WaitToQuit(gss ...GracefulService) {
 	<-gc.ctx.Done()
 
-	quitCtx, quitCtxCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if gc.preStopDelay > 0 {
+		log.Infof("Wait %v before stopping services", gc.preStopDelay)
+		time.Sleep(gc.preStopDelay)
+	}
+
+	quitCtx, quitCtxCancel := context.WithTimeout(context.Background(), gc.quitTimeout)
 
 	go func() {
 		log.Infof("Start to stop services")
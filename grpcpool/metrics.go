@@ -0,0 +1,27 @@
+package grpcpool
+
+import (
+	"sync/atomic"
+
+	"github.com/madlabx/pkgx/errors"
+	"github.com/madlabx/pkgx/metricx"
+)
+
+// RegisterMetrics exposes the physical connections and logic streams of p in reg,
+// e.g. the registry of httpx.ApiGateway.
+func RegisterMetrics(reg *metricx.Registry, p Pool) error {
+	pl, ok := p.(*pool)
+	if !ok {
+		return errors.Errorf("unsupported pool type:%T", p)
+	}
+
+	labels := metricx.Labels{"address": pl.address}
+	reg.NewGaugeFunc("grpcpool_connections", "Number of physical connections in the pool.", labels,
+		func() float64 { return float64(atomic.LoadInt32(&pl.current)) })
+	reg.NewGaugeFunc("grpcpool_streams", "Number of logic connections in use.", labels,
+		func() float64 { return float64(atomic.LoadInt32(&pl.ref)) })
+	reg.NewGaugeFunc("grpcpool_max_connections", "Maximum number of physical connections of the pool.", labels,
+		func() float64 { return float64(pl.opt.MaxActive) })
+
+	return nil
+}
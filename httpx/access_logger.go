@@ -3,11 +3,13 @@ package httpx
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +18,8 @@ import (
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
 	"github.com/labstack/gommon/color"
+	"github.com/madlabx/pkgx/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/valyala/fasttemplate"
 )
 
@@ -27,8 +31,38 @@ const (
 	AccessLogBeforeRun AccessLogTiming = "before"
 	AccessLogAfterRun  AccessLogTiming = "after"
 	AccessLogBoth      AccessLogTiming = "both"
+
+	// AccessLogTemplate writes the lines rendered by FormatBefore and FormatAfter to Output
+	AccessLogTemplate AccessLogMode = "template"
+	// AccessLogFields logs an entry with Fields to FieldLogger after run
+	AccessLogFields AccessLogMode = "fields"
+	// AccessLogJSON writes a JSON line of Fields to Output after run
+	AccessLogJSON AccessLogMode = "json"
 )
 
+type AccessLogMode string
+
+// validate accepts the empty mode as AccessLogTemplate
+func (m AccessLogMode) validate() error {
+	switch m {
+	case "", AccessLogTemplate, AccessLogFields, AccessLogJSON:
+		return nil
+	}
+	return errors.Errorf("invalid access log mode:%v", m)
+}
+
+// DefaultPrintableContentTypes are the content types of the bodies printed by default
+var DefaultPrintableContentTypes = []string{
+	echo.MIMEApplicationJSON, "application/*+json", "application/x-ndjson",
+	echo.MIMEApplicationForm, "text/*", echo.MIMEApplicationXML, "application/*+xml",
+}
+
+// DefaultAccessLogFields are logged by AccessLogFields and AccessLogJSON if LoggerConfig.Fields is empty
+var DefaultAccessLogFields = []string{
+	"time_rfc3339_nano", "id", "remote_ip", "host", "method", "uri", "user_agent",
+	"status", "error", "latency", "bytes_in", "bytes_out",
+}
+
 type (
 	Filter func(echo.Context) bool
 
@@ -40,6 +74,13 @@ type (
 		// OutBodyFilter defines a function to print body_out, false by default due to additional memory used
 		OutBodyFilter middleware.Skipper
 
+		// InBodySkipper defines a function to skip body_in, which is printed by default
+		InBodySkipper middleware.Skipper
+
+		// PrintableContentTypes are the content types of body_in and body_out printed, e.g. "text/*"
+		// or "application/*+json". Optional. Default value DefaultPrintableContentTypes.
+		PrintableContentTypes []string `yaml:"printable_content_types"`
+
 		// Tags to construct the logger format.
 		//
 		// - time_unix
@@ -56,6 +97,7 @@ type (
 		// - protocol
 		// - referer
 		// - user_agent
+		// - client_cn (CommonName of client certificate)
 		// - status
 		// - error
 		// - latency (In nanoseconds)
@@ -68,6 +110,9 @@ type (
 		// - form:<NAME>
 		// - body_in (request body)
 		// - body_out (response body)   , should also define OutBodyFilter to log only necessary.
+		// - headers_in (request headers in JSON)
+		// - headers_out (response headers in JSON)
+		// - log_reason (error or slow, why the request is always logged by Sampling)
 		//
 		// Example "${remote_ip} ${status}"
 		//
@@ -76,6 +121,27 @@ type (
 		FormatBefore string `yaml:"format_before"`
 		Timing       AccessLogTiming
 
+		// Mode is AccessLogTemplate by default, Timing is ignored by AccessLogFields and AccessLogJSON
+		Mode AccessLogMode `yaml:"mode"`
+		// Fields are the tags logged by AccessLogFields and AccessLogJSON, e.g. "status" or
+		// "header_in:X-Api-Key", status, latency (in milliseconds), bytes_in and bytes_out are numbers.
+		// Optional. Default value DefaultAccessLogFields.
+		Fields []string `yaml:"fields"`
+		// FieldLogger logs the entries of AccessLogFields, sharing the formatter and hooks of
+		// the application logs. Optional. Default value logrus.StandardLogger().
+		FieldLogger logrus.FieldLogger
+
+		// Sampling logs a part of the requests, and the failed or slow ones with details. Optional.
+		// Default value nil, which logs all the requests.
+		Sampling *AccessLogSampling `yaml:"sampling"`
+		// DetailFormat is the full-detail line of AccessLogTemplate for the requests always logged
+		// by Sampling. Optional. Default value DefaultAccessLogDetailFormat.
+		DetailFormat string `yaml:"detail_format"`
+
+		// Redact masks the sensitive values of headers, query, cookies and bodies before logging.
+		// Optional. Default value nil, which redacts DefaultRedactRules.
+		Redact *RedactConfig `yaml:"redact"`
+
 		// Optional. Default value DefaultLoggerConfig.CustomTimeFormat.
 		CustomTimeFormat string `yaml:"custom_time_format"`
 
@@ -85,6 +151,9 @@ type (
 
 		templateAfter  *fasttemplate.Template
 		templateBefore *fasttemplate.Template
+		templateDetail *fasttemplate.Template
+		sampler        *accessLogSampler
+		redactor       *redactor
 		colorer        *color.Color
 		pool           *sync.Pool
 		bodyBufferSize int64
@@ -97,8 +166,8 @@ var (
 		Skipper:       middleware.DefaultSkipper,
 		OutBodyFilter: DefaultOutBodyFilter,
 		FormatBefore: `{"time":"${time_rfc3339_nano}","id":"${id}","remote_ip":"${remote_ip}",` +
-			`"host":"${host}","method":"${method}","uri":"${uri}","user_agent":"${user_agent}",` +
-			`,"bytes_in":${bytes_in}`,
+			`"host":"${host}","method":"${method}","uri":"${uri}","user_agent":"${user_agent}"` +
+			`,"bytes_in":${bytes_in}}`,
 		FormatAfter: `{"time":"${time_rfc3339_nano}","id":"${id}","remote_ip":"${remote_ip}",` +
 			`"host":"${host}","method":"${method}","uri":"${uri}","user_agent":"${user_agent}",` +
 			`"status":${status},"error":"${error}","latency":${latency},"latency_human":"${latency_human}"` +
@@ -109,8 +178,6 @@ var (
 		bodyBufferSize:   defaultBufSize,
 		Timing:           AccessLogBoth,
 	}
-	configAccess *LoggerConfig
-	once         sync.Once
 )
 
 // DefaultOutBodyFilter returns false which processes the middleware.
@@ -124,10 +191,6 @@ func DefaultOutBodyFilter(echo.Context) bool {
 //}
 
 func LoggerWithConfig(config LoggerConfig) echo.MiddlewareFunc {
-	once.Do(func() {
-		configAccess = &config
-	})
-
 	// Defaults
 	if config.Skipper == nil {
 		config.Skipper = DefaultLoggerConfig.Skipper
@@ -145,19 +208,52 @@ func LoggerWithConfig(config LoggerConfig) echo.MiddlewareFunc {
 		config.Timing = DefaultLoggerConfig.Timing
 	}
 
-	if config.Timing != AccessLogAfterRun {
+	if len(config.PrintableContentTypes) == 0 {
+		config.PrintableContentTypes = DefaultPrintableContentTypes
+	}
+
+	if err := config.Mode.validate(); err != nil {
+		panic(err)
+	}
+	if config.Mode == "" {
+		config.Mode = AccessLogTemplate
+	}
+	if config.Mode != AccessLogTemplate {
+		// one entry per request
+		config.Timing = ""
+		if len(config.Fields) == 0 {
+			config.Fields = DefaultAccessLogFields
+		}
+		if config.FieldLogger == nil {
+			config.FieldLogger = logrus.StandardLogger()
+		}
+	}
+
+	if config.Timing == AccessLogBeforeRun || config.Timing == AccessLogBoth {
 		if config.FormatBefore == "" {
 			config.FormatBefore = DefaultLoggerConfig.FormatBefore
 		}
 		config.templateBefore = fasttemplate.New(config.FormatBefore+"\n", "${", "}")
 	}
 
-	if config.Timing != AccessLogBeforeRun {
+	if config.Timing == AccessLogAfterRun || config.Timing == AccessLogBoth {
 		if config.FormatAfter == "" {
 			config.FormatAfter = DefaultLoggerConfig.FormatAfter
 		}
 		config.templateAfter = fasttemplate.New(config.FormatAfter+"\n", "${", "}")
 	}
+	config.sampler = newAccessLogSampler(config.Sampling)
+	redactor, err := newRedactor(config.Redact)
+	if err != nil {
+		panic(err)
+	}
+	config.redactor = redactor
+	if config.sampler != nil && !config.sampler.config.DisableDetail && config.Mode == AccessLogTemplate {
+		if config.DetailFormat == "" {
+			config.DetailFormat = DefaultAccessLogDetailFormat
+		}
+		config.templateDetail = fasttemplate.New(config.DetailFormat+"\n", "${", "}")
+	}
 	config.colorer = color.New()
 	config.colorer.SetOutput(config.Output)
 	config.pool = &sync.Pool{
@@ -166,28 +262,46 @@ func LoggerWithConfig(config LoggerConfig) echo.MiddlewareFunc {
 		},
 	}
 
-	loggingRequestBody := func(c echo.Context, bytesIn int64) string {
-		if bytesIn > 0 && bytesIn <= config.bodyBufferSize &&
-			isPrintableTextContent(c.Request().Header.Get(echo.HeaderContentType)) {
-			// Request
-			var reqBody []byte
-			if c.Request().Body != nil { // Read
-				reqBody, _ = io.ReadAll(c.Request().Body)
-			}
-			c.Request().Body = io.NopCloser(bytes.NewBuffer(reqBody)) // Reset
-			bytesIn = min(bytesIn, int64(len(reqBody)))
-			return fmt.Sprintf("in[%v]:%v", bytesIn, string(reqBody[:bytesIn]))
+	// requestBody reads ahead the unread body up to bodyBufferSize, so that it is captured
+	// even if the handler does not read it, or before run
+	requestBody := func(c echo.Context, reqBody *teeBody) (string, bool) {
+		if reqBody == nil {
+			return "", false
+		}
+		reqBody.fill()
+		if reqBody.buf.n == 0 {
+			return "", false
+		}
+		body := config.redactor.body(c.Request().Header.Get(echo.HeaderContentType), string(reqBody.buf.Bytes()))
+		if reqBody.truncated() {
+			body += bodyTruncatedMarker
+		}
+		return body, true
+	}
+
+	loggingRequestBody := func(c echo.Context, reqBody *teeBody, bytesIn int64) string {
+		if body, ok := requestBody(c, reqBody); ok {
+			return fmt.Sprintf("in[%v]:%v", len(body), body)
 		}
 		return fmt.Sprintf("in[%v]", bytesIn)
 	}
 
-	loggingResponseBody := func(c echo.Context, doPrintBodyOut bool, bytesOut int64, respBody []byte) string {
-		if doPrintBodyOut && bytesOut > 0 && bytesOut <= config.bodyBufferSize &&
-			isPrintableTextContent(c.Response().Header().Get(echo.HeaderContentType)) {
-			//skip "\n"
-			bytesOut = min(bytesOut, int64(len(respBody)))
-			bytesOut = max(0, bytesOut-1)
-			return fmt.Sprintf("out[%v]:%v", bytesOut, string(respBody[:bytesOut]))
+	responseBody := func(c echo.Context, doPrintBodyOut bool, bytesOut int64, respBody *limitBuffer) (string, bool) {
+		contentType := c.Response().Header().Get(echo.HeaderContentType)
+		// nothing captured if the writer is unwrapped, e.g. by ServeSSE
+		if !doPrintBodyOut || config.bodyBufferSize <= 0 || respBody.n == 0 || !matchContentType(config.PrintableContentTypes, contentType) {
+			return "", false
+		}
+		if bytesOut > int64(respBody.n) {
+			return config.redactor.body(contentType, string(respBody.Bytes())) + bodyTruncatedMarker, true
+		}
+		//skip "\n"
+		return config.redactor.body(contentType, strings.TrimSuffix(string(respBody.Bytes()), "\n")), true
+	}
+
+	loggingResponseBody := func(c echo.Context, doPrintBodyOut bool, bytesOut int64, respBody *limitBuffer) string {
+		if body, ok := responseBody(c, doPrintBodyOut, bytesOut, respBody); ok {
+			return fmt.Sprintf("out[%v]:%v", len(body), body)
 		}
 		return fmt.Sprintf("out[%v]", bytesOut)
 	}
@@ -201,6 +315,33 @@ func LoggerWithConfig(config LoggerConfig) echo.MiddlewareFunc {
 			req := c.Request()
 			res := c.Response()
 			start := time.Now()
+			// reason is set by Sampling after run
+			reason := ""
+
+			var reqBody *teeBody
+			if config.bodyBufferSize > 0 && req.Body != nil && req.Body != http.NoBody &&
+				(config.InBodySkipper == nil || !config.InBodySkipper(c)) &&
+				matchContentType(config.PrintableContentTypes, req.Header.Get(echo.HeaderContentType)) {
+				reqBody = newTeeBody(req.Body, config.bodyBufferSize)
+				req.Body = reqBody
+			}
+			var counter *countingBody
+			if reqBody == nil && req.ContentLength < 0 && req.Body != nil && req.Body != http.NoBody {
+				counter = &countingBody{ReadCloser: req.Body}
+				req.Body = counter
+			}
+			// bytesIn is the bytes read from the body of unknown length, e.g. chunked
+			bytesIn := func() int64 {
+				switch {
+				case req.ContentLength >= 0:
+					return req.ContentLength
+				case reqBody != nil:
+					return reqBody.read
+				case counter != nil:
+					return counter.n
+				}
+				return 0
+			}
 
 			doPrintBodyOut := config.OutBodyFilter(c)
 			respBody := newLimitBuffer(config.bodyBufferSize)
@@ -223,7 +364,10 @@ func LoggerWithConfig(config LoggerConfig) echo.MiddlewareFunc {
 				case "time_custom":
 					return buf.WriteString(time.Now().Format(config.CustomTimeFormat))
 				case "id":
-					id := req.Header.Get(echo.HeaderXRequestID)
+					id := GetRequestId(c)
+					if id == "" {
+						id = req.Header.Get(echo.HeaderXRequestID)
+					}
 					if id == "" {
 						id = res.Header().Get(echo.HeaderXRequestID)
 					}
@@ -233,7 +377,7 @@ func LoggerWithConfig(config LoggerConfig) echo.MiddlewareFunc {
 				case "host":
 					return buf.WriteString(req.Host)
 				case "uri":
-					return buf.WriteString(req.RequestURI)
+					return buf.WriteString(config.redactor.uri(req.RequestURI))
 				case "method":
 					return buf.WriteString(req.Method)
 				case "path":
@@ -248,20 +392,15 @@ func LoggerWithConfig(config LoggerConfig) echo.MiddlewareFunc {
 					return buf.WriteString(req.Referer())
 				case "user_agent":
 					return buf.WriteString(req.UserAgent())
+				case "client_cn":
+					if ci := GetClientIdentity(c); ci != nil {
+						return buf.WriteString(ci.CommonName)
+					}
 
 				case "bytes_in":
-					cl := req.Header.Get(echo.HeaderContentLength)
-					if cl == "" {
-						cl = "0"
-					}
-					return buf.WriteString(cl)
+					return buf.WriteString(strconv.FormatInt(bytesIn(), 10))
 				case "body_in":
-					cl := req.Header.Get(echo.HeaderContentLength)
-					if cl == "" {
-						cl = "0"
-					}
-					bytesIn, _ := strconv.Atoi(cl)
-					return buf.WriteString(loggingRequestBody(c, int64(bytesIn)))
+					return buf.WriteString(loggingRequestBody(c, reqBody, bytesIn()))
 
 				case "latency":
 					l := time.Now().Sub(start).Milliseconds()
@@ -271,7 +410,17 @@ func LoggerWithConfig(config LoggerConfig) echo.MiddlewareFunc {
 				case "bytes_out":
 					return buf.WriteString(strconv.FormatInt(res.Size, 10))
 				case "body_out":
-					return buf.WriteString(loggingResponseBody(c, doPrintBodyOut, res.Size, respBody.Bytes()))
+					return buf.WriteString(loggingResponseBody(c, doPrintBodyOut, res.Size, respBody))
+				case "headers_in":
+					return buf.Write(marshalHeaders(config.redactor.headers(req.Header)))
+				case "headers_out":
+					return buf.Write(marshalHeaders(config.redactor.headers(res.Header())))
+				case "log_reason":
+					return buf.WriteString(reason)
+				case "error":
+					if err != nil {
+						return buf.WriteString(err.Error())
+					}
 				case "status":
 					n := res.Status
 					s := config.colorer.Green(n)
@@ -287,27 +436,59 @@ func LoggerWithConfig(config LoggerConfig) echo.MiddlewareFunc {
 				default:
 					switch {
 					case strings.HasPrefix(tag, "header_in:"):
-						return buf.Write([]byte(c.Request().Header.Get(tag[10:])))
+						return buf.WriteString(config.redactor.header(tag[10:], c.Request().Header.Get(tag[10:])))
 					case strings.HasPrefix(tag, "header_out:"):
-						return buf.Write([]byte(c.Response().Header().Get(tag[11:])))
+						return buf.WriteString(config.redactor.header(tag[11:], c.Response().Header().Get(tag[11:])))
 					case strings.HasPrefix(tag, "query:"):
-						return buf.Write([]byte(c.QueryParam(tag[6:])))
+						return buf.WriteString(config.redactor.query(tag[6:], c.QueryParam(tag[6:])))
 					case strings.HasPrefix(tag, "form:"):
-						return buf.Write([]byte(c.FormValue(tag[5:])))
+						return buf.WriteString(config.redactor.query(tag[5:], c.FormValue(tag[5:])))
 					case strings.HasPrefix(tag, "cookie:"):
 						cookie, err := c.Cookie(tag[7:])
 						if err == nil {
-							return buf.Write([]byte(cookie.Value))
+							return buf.WriteString(config.redactor.header(echo.HeaderCookie, cookie.Value))
 						}
 					}
 				}
 				return 0, nil
 			}
 
+			fieldValue := func(tag string) any {
+				switch tag {
+				case "status":
+					return res.Status
+				case "latency":
+					return time.Since(start).Milliseconds()
+				case "bytes_in":
+					return bytesIn()
+				case "bytes_out":
+					return res.Size
+				case "headers_in":
+					return config.redactor.headers(req.Header)
+				case "headers_out":
+					return config.redactor.headers(res.Header())
+				case "body_in":
+					body, _ := requestBody(c, reqBody)
+					return body
+				case "body_out":
+					body, _ := responseBody(c, doPrintBodyOut, res.Size, respBody)
+					return body
+				}
+				var b bytes.Buffer
+				_, _ = loggingTemplate(&b, tag)
+				return b.String()
+			}
+
 			buf := config.pool.Get().(*bytes.Buffer)
 			defer config.pool.Put(buf)
 
-			if config.templateBefore != nil {
+			// the same roll for the before and after lines
+			var roll float64
+			if config.sampler != nil {
+				roll = config.sampler.roll()
+			}
+
+			if config.templateBefore != nil && (config.sampler == nil || config.sampler.sampledBefore(c, roll)) {
 				//Log after run
 				buf.Reset()
 				if _, err = config.templateBefore.ExecuteFunc(buf, func(w io.Writer, tag string) (int, error) {
@@ -324,27 +505,164 @@ func LoggerWithConfig(config LoggerConfig) echo.MiddlewareFunc {
 				c.Error(err)
 			}
 
-			if config.templateAfter == nil {
-				return
+			logAfter, detail := true, false
+			if config.sampler != nil {
+				logAfter, reason = config.sampler.sampledAfter(c, res.Status, time.Since(start), roll)
+				detail = reason != "" && !config.sampler.config.DisableDetail
+			}
+			tags := config.Fields
+			if detail {
+				tags = append(append([]string{}, tags...), accessLogDetailTags...)
 			}
 
-			//Log after run
-			buf.Reset()
-			if _, err = config.templateAfter.ExecuteFunc(buf, func(w io.Writer, tag string) (int, error) {
-				return loggingTemplate(buf, tag)
-			}); err != nil {
+			switch config.Mode {
+			case AccessLogFields:
+				if !logAfter {
+					return nil
+				}
+				fields := make(logrus.Fields, len(tags))
+				for _, tag := range tags {
+					fields[tag] = fieldValue(tag)
+				}
+				config.FieldLogger.WithFields(fields).Info("access")
+				return nil
+			case AccessLogJSON:
+				if !logAfter {
+					return nil
+				}
+				buf.Reset()
+				buf.WriteByte('{')
+				for i, tag := range tags {
+					if i > 0 {
+						buf.WriteByte(',')
+					}
+					k, _ := json.Marshal(tag)
+					v, jerr := json.Marshal(fieldValue(tag))
+					if jerr != nil {
+						v, _ = json.Marshal(jerr.Error())
+					}
+					buf.Write(k)
+					buf.WriteByte(':')
+					buf.Write(v)
+				}
+				buf.WriteString("}\n")
+				_, err = config.Output.Write(buf.Bytes())
 				return
 			}
 
-			_, err = config.Output.Write(buf.Bytes())
+			if config.templateAfter != nil && logAfter {
+				//Log after run
+				buf.Reset()
+				if _, err = config.templateAfter.ExecuteFunc(buf, func(w io.Writer, tag string) (int, error) {
+					return loggingTemplate(buf, tag)
+				}); err != nil {
+					return
+				}
+				if _, err = config.Output.Write(buf.Bytes()); err != nil {
+					return
+				}
+			}
 
-			return
+			if config.templateDetail != nil && detail {
+				buf.Reset()
+				if _, err = config.templateDetail.ExecuteFunc(buf, func(w io.Writer, tag string) (int, error) {
+					return loggingTemplate(buf, tag)
+				}); err != nil {
+					return
+				}
+				if _, err = config.Output.Write(buf.Bytes()); err != nil {
+					return
+				}
+			}
+
+			return nil
+		}
+	}
+}
+
+// accessLogDetailTags are added to Fields for the requests always logged by Sampling
+var accessLogDetailTags = []string{"log_reason", "headers_in", "headers_out", "body_in", "body_out"}
+
+func marshalHeaders(h http.Header) []byte {
+	b, _ := json.Marshal(h)
+	return b
+}
+
+// matchContentType reports whether the media type of contentType matches one of patterns, e.g. text/*
+func matchContentType(patterns []string, contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" {
+		return false
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, mediaType); ok {
+			return true
 		}
 	}
+	return false
+}
+
+// bodyTruncatedMarker is appended to body_in and body_out longer than the buffer
+const bodyTruncatedMarker = "...(truncated)"
+
+// teeBody captures the first bytes of the request body read by the handler, or read ahead by fill
+type teeBody struct {
+	io.ReadCloser
+	buf     *limitBuffer
+	pending []byte
+	read    int64
+	// err is the error of the underlying body returned after pending, io.EOF mostly
+	err error
+}
+
+func newTeeBody(rc io.ReadCloser, size int64) *teeBody {
+	return &teeBody{ReadCloser: rc, buf: newLimitBuffer(size)}
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	if len(b.pending) > 0 {
+		n := copy(p, b.pending)
+		b.pending = b.pending[n:]
+		return n, nil
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	_, _ = b.buf.Write(p[:n])
+	b.read += int64(n)
+	b.err = err
+	return n, err
+}
+
+// fill reads ahead until the buffer overflows or an error, the bytes are replayed to the handler
+func (b *teeBody) fill() {
+	limit := int64(len(b.buf.buf))
+	for b.err == nil && b.read <= limit {
+		p := make([]byte, limit+1-b.read)
+		n, err := b.ReadCloser.Read(p)
+		_, _ = b.buf.Write(p[:n])
+		b.read += int64(n)
+		b.pending = append(b.pending, p[:n]...)
+		b.err = err
+	}
+}
+
+func (b *teeBody) truncated() bool {
+	return b.read > int64(b.buf.n)
+}
+
+// countingBody counts the bytes of the request body read by the handler
+type countingBody struct {
+	io.ReadCloser
+	n int64
 }
 
-func isPrintableTextContent(contentType string) bool {
-	return strings.HasPrefix(contentType, echo.MIMEApplicationJSON)
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
 }
 
 type bodyDumpResponseWriter struct {
@@ -0,0 +1,171 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/log"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultLoggerFormat(t *testing.T) {
+	var out bytes.Buffer
+	e := echo.New()
+	e.Use(LoggerWithConfig(LoggerConfig{Output: &out, OutBodyFilter: DefaultOutBodyFilter}))
+	e.GET("/v1/ping", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/ping", nil))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		require.True(t, json.Valid([]byte(line)), line)
+	}
+}
+
+func TestAccessLogFields(t *testing.T) {
+	var out bytes.Buffer
+	agw := newTestApiGateway(t)
+	agw.LogConf.Mode = AccessLogFields
+	agw.Logger.Out = &out
+	agw.Logger.SetFormatter(&logrus.JSONFormatter{})
+	agw.configEcho()
+	agw.POST("/v1/users", func(c echo.Context) error { return SendResp(c, SuccessResp(nil)) })
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader("{}"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	serveTestRequest(agw, req)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry), out.String())
+	require.Equal(t, "access", entry["msg"])
+	require.Equal(t, "info", entry["level"])
+	require.Equal(t, http.MethodPost, entry["method"])
+	require.Equal(t, "/v1/users", entry["uri"])
+	require.Equal(t, float64(http.StatusOK), entry["status"])
+	require.Equal(t, float64(2), entry["bytes_in"])
+	require.NotEmpty(t, entry["id"])
+	require.Contains(t, entry, "latency")
+	require.NotContains(t, entry, "body_in")
+}
+
+func TestAccessLogBytesInChunked(t *testing.T) {
+	var out bytes.Buffer
+	agw := newTestApiGateway(t)
+	agw.LogConf.Mode = AccessLogJSON
+	agw.LogConf.Fields = []string{"path", "bytes_in"}
+	agw.LogConf.BodyBufferSize = 4
+	agw.Logger.Out = &out
+	agw.configEcho()
+	agw.POST("/v1/upload", func(c echo.Context) error {
+		_, _ = io.Copy(io.Discard, c.Request().Body)
+		return SendResp(c, SuccessResp(nil))
+	})
+
+	for _, contentType := range []string{echo.MIMEApplicationJSON, echo.MIMEOctetStream} {
+		out.Reset()
+		// unknown length, as chunked
+		req := httptest.NewRequest(http.MethodPost, "/v1/upload", strings.NewReader(`{"a":"0123456789"}`))
+		req.ContentLength = -1
+		req.Header.Set(echo.HeaderContentType, contentType)
+		serveTestRequest(agw, req)
+		require.Equal(t, `{"path":"/v1/upload","bytes_in":18}`+"\n", out.String(), contentType)
+	}
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	var out bytes.Buffer
+	agw := newTestApiGateway(t)
+	agw.LogConf.Mode = AccessLogJSON
+	agw.LogConf.Fields = []string{"method", "path", "status", "error", "header_in:X-Api-Key", "body_in"}
+	agw.LogConf.BodyBufferSize = 1024
+	agw.Logger.Out = &out
+	agw.configEcho()
+	agw.POST("/v1/teapot", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusTeapot, "short and stout")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/teapot", strings.NewReader(`{"a":"b"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-Api-Key", "k1")
+	serveTestRequest(agw, req)
+
+	require.Equal(t, `{"method":"POST","path":"/v1/teapot","status":418,`+
+		`"error":"code=418, message=short and stout","header_in:X-Api-Key":"k1","body_in":"{\"a\":\"b\"}"}`+"\n",
+		out.String())
+}
+
+func TestAccessLogMode(t *testing.T) {
+	require.Panics(t, func() { LoggerWithConfig(LoggerConfig{Mode: "JSON"}) })
+	require.NotPanics(t, func() { LoggerWithConfig(LoggerConfig{Mode: AccessLogFields}) })
+
+	_, err := NewApiGateway(context.Background(), "127.0.0.1", "0", "test", &LogConfig{
+		LogFile: log.FileConfig{Filename: "discard"},
+		Level:   "info",
+		Mode:    "text",
+	}, nil)
+	require.ErrorContains(t, err, "invalid access log mode:text")
+}
+
+func TestMatchContentType(t *testing.T) {
+	for _, ct := range []string{
+		echo.MIMEApplicationJSONCharsetUTF8, "application/problem+json", "application/x-ndjson",
+		echo.MIMEApplicationForm, "text/plain; charset=utf-8", "TEXT/CSV", echo.MIMEApplicationXML,
+	} {
+		require.True(t, matchContentType(DefaultPrintableContentTypes, ct), ct)
+	}
+	for _, ct := range []string{"", echo.MIMEOctetStream, echo.MIMEMultipartForm, "image/png"} {
+		require.False(t, matchContentType(DefaultPrintableContentTypes, ct), ct)
+	}
+}
+
+func TestAccessLogBody(t *testing.T) {
+	var out bytes.Buffer
+	agw := newTestApiGateway(t)
+	agw.LogConf.ContentFormatBefore = "BEF ${body_in}"
+	agw.LogConf.ContentFormatAfter = "AFT ${body_in} ${body_out}"
+	agw.LogConf.BodyBufferSize = 16
+	agw.LogConf.Redact = RedactConfig{Rules: []RedactRule{{Query: "password"}}}
+	agw.Logger.Out = &out
+	agw.configEcho()
+	agw.POST("/v1/echo", func(c echo.Context) error {
+		b, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, c.Request().Header.Get(echo.HeaderContentType), b)
+	})
+	agw.POST("/v1/ignore", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+
+	serve := func(path, contentType, body string) (string, string) {
+		out.Reset()
+		// chunked without Content-Length
+		req := httptest.NewRequest(http.MethodPost, path, io.MultiReader(strings.NewReader(body)))
+		req.ContentLength = -1
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := serveTestRequest(agw, req)
+		return out.String(), rec.Body.String()
+	}
+
+	logs, resp := serve("/v1/echo", echo.MIMETextPlain, "hello")
+	require.Equal(t, "hello", resp)
+	require.Equal(t, "BEF in[5]:hello\nAFT in[5]:hello out[5]:hello\n", logs)
+
+	logs, resp = serve("/v1/echo", echo.MIMETextPlain, "0123456789abcdefXYZ")
+	require.Equal(t, "0123456789abcdefXYZ", resp)
+	require.Equal(t, "BEF in[30]:0123456789abcdef...(truncated)\n"+
+		"AFT in[30]:0123456789abcdef...(truncated) out[30]:0123456789abcdef...(truncated)\n", logs)
+
+	logs, _ = serve("/v1/ignore", echo.MIMEApplicationForm, "a=1&password=p")
+	require.Contains(t, logs, "AFT in[31]:a=1&password=%2A%2A%2A%2A%2A%2A out[0]\n")
+
+	logs, _ = serve("/v1/ignore", echo.MIMEOctetStream, "binary")
+	require.Equal(t, "BEF in[0]\nAFT in[0] out[0]\n", logs)
+}
@@ -0,0 +1,336 @@
+package httpx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/errors"
+)
+
+const (
+	// RedactFull replaces the value with a fixed mask
+	RedactFull RedactMode = "full"
+	// RedactPartial keeps a quarter of the characters at both ends up to 4, e.g. Bear******1c2d
+	RedactPartial RedactMode = "partial"
+	// RedactHash replaces the value with a short SHA-256, so that the same values can be correlated
+	RedactHash RedactMode = "hash"
+
+	redactMask = "******"
+)
+
+// RedactMode is the masking strategy of the redacted values
+type RedactMode string
+
+// DefaultRedactRules are applied unless RedactConfig.Disable
+var DefaultRedactRules = []RedactRule{
+	{Header: echo.HeaderAuthorization},
+	{Header: echo.HeaderCookie},
+	{Header: echo.HeaderSetCookie},
+}
+
+// RedactRule targets one of Header, Query, JSONPath and Regex
+type RedactRule struct {
+	// Header is the name of the header, also the cookies of Cookie and Set-Cookie
+	Header string
+	// Query is the key of the query and form parameters
+	Query string
+	// JSONPath is the dot separated path of the field in JSON bodies, * matches any key,
+	// and arrays are traversed, e.g. user.password, items.*.token
+	JSONPath string
+	// Regex masks the matches in uri and bodies
+	Regex string
+	// Mode is RedactConfig.Mode if empty
+	Mode RedactMode
+}
+
+// RedactConfig defines the redaction of the access logs, e.g.
+//
+//	[Http.Log.Redact]
+//	Mode = "partial"
+//	[[Http.Log.Redact.Rules]]
+//	JSONPath = "password"
+//	Mode = "full"
+type RedactConfig struct {
+	// Disable turns off the redaction, including DefaultRedactRules
+	Disable bool
+	// Mode is the masking of the rules without Mode
+	Mode RedactMode `vx_default:"full"`
+	// Rules are applied before DefaultRedactRules, so that they may override the defaults
+	Rules []RedactRule
+}
+
+type redactPath struct {
+	segments []string
+	mode     RedactMode
+	// fallback matches the value of the last key in the bodies not valid JSON, e.g. truncated or NDJSON
+	fallback *regexp.Regexp
+}
+
+type redactPattern struct {
+	re   *regexp.Regexp
+	mode RedactMode
+}
+
+type redactor struct {
+	headerModes map[string]RedactMode
+	queryModes  map[string]RedactMode
+	paths       []redactPath
+	patterns    []redactPattern
+}
+
+// newRedactor returns nil if the redaction is disabled, the methods of nil redactor return the values as is
+func newRedactor(config *RedactConfig) (*redactor, error) {
+	if config == nil {
+		config = &RedactConfig{}
+	}
+	if config.Disable {
+		return nil, nil
+	}
+
+	r := &redactor{headerModes: map[string]RedactMode{}, queryModes: map[string]RedactMode{}}
+	for _, rule := range append(append([]RedactRule{}, config.Rules...), DefaultRedactRules...) {
+		mode := rule.Mode
+		if mode == "" {
+			mode = config.Mode
+		}
+		if mode == "" {
+			mode = RedactFull
+		}
+		switch mode {
+		case RedactFull, RedactPartial, RedactHash:
+		default:
+			return nil, errors.Errorf("invalid redact mode:%v", mode)
+		}
+
+		switch {
+		case rule.Header != "":
+			key := http.CanonicalHeaderKey(rule.Header)
+			if _, ok := r.headerModes[key]; !ok {
+				r.headerModes[key] = mode
+			}
+		case rule.Query != "":
+			if _, ok := r.queryModes[rule.Query]; !ok {
+				r.queryModes[rule.Query] = mode
+			}
+		case rule.JSONPath != "":
+			p := redactPath{segments: strings.Split(rule.JSONPath, "."), mode: mode}
+			if key := p.segments[len(p.segments)-1]; key != "*" {
+				p.fallback = regexp.MustCompile(`"` + regexp.QuoteMeta(key) + `"\s*:\s*("(?:[^"\\]|\\.)*"?|[^\s,}\]]+)`)
+			}
+			r.paths = append(r.paths, p)
+		case rule.Regex != "":
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid redact regex:%v", rule.Regex)
+			}
+			r.patterns = append(r.patterns, redactPattern{re: re, mode: mode})
+		default:
+			return nil, errors.Errorf("empty redact rule:%+v", rule)
+		}
+	}
+	return r, nil
+}
+
+func (m RedactMode) mask(s string) string {
+	switch m {
+	case RedactHash:
+		sum := sha256.Sum256([]byte(s))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	case RedactPartial:
+		n := utf8.RuneCountInString(s)
+		keep := min(n/4, 4)
+		if keep == 0 {
+			return redactMask
+		}
+		runes := []rune(s)
+		return string(runes[:keep]) + redactMask + string(runes[n-keep:])
+	default:
+		return redactMask
+	}
+}
+
+// header redacts the value of the header name
+func (r *redactor) header(name, value string) string {
+	if r == nil || value == "" {
+		return value
+	}
+	if mode, ok := r.headerModes[http.CanonicalHeaderKey(name)]; ok {
+		return mode.mask(value)
+	}
+	return value
+}
+
+// headers returns h itself if nothing is redacted, otherwise a redacted copy
+func (r *redactor) headers(h http.Header) http.Header {
+	if r == nil {
+		return h
+	}
+	var redacted http.Header
+	for key, mode := range r.headerModes {
+		values, ok := h[key]
+		if !ok {
+			continue
+		}
+		if redacted == nil {
+			redacted = h.Clone()
+		}
+		masked := make([]string, len(values))
+		for i, v := range values {
+			masked[i] = mode.mask(v)
+		}
+		redacted[key] = masked
+	}
+	if redacted == nil {
+		return h
+	}
+	return redacted
+}
+
+// query redacts the value of the query or form parameter key
+func (r *redactor) query(key, value string) string {
+	if r == nil || value == "" {
+		return value
+	}
+	if mode, ok := r.queryModes[key]; ok {
+		return mode.mask(value)
+	}
+	return value
+}
+
+// uri redacts the query parameters of uri in place, then the regexes
+func (r *redactor) uri(uri string) string {
+	if r == nil {
+		return uri
+	}
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		uri = uri[:i+1] + r.rawQuery(uri[i+1:])
+	}
+	return r.regex(uri)
+}
+
+// rawQuery redacts the values of the url-encoded query in place
+func (r *redactor) rawQuery(query string) string {
+	if len(r.queryModes) == 0 {
+		return query
+	}
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			continue
+		}
+		if mode, ok := r.queryModes[key]; ok {
+			value, _ := url.QueryUnescape(v)
+			pairs[i] = k + "=" + url.QueryEscape(mode.mask(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// body redacts the JSON paths of JSON bodies, the query keys of form bodies, then the regexes
+func (r *redactor) body(contentType, body string) string {
+	if r == nil || body == "" {
+		return body
+	}
+	switch {
+	case len(r.paths) > 0 && strings.Contains(contentType, "json"):
+		body = r.json(body)
+	case strings.HasPrefix(contentType, echo.MIMEApplicationForm):
+		body = r.rawQuery(body)
+	}
+	return r.regex(body)
+}
+
+func (r *redactor) regex(s string) string {
+	for _, p := range r.patterns {
+		s = p.re.ReplaceAllStringFunc(s, p.mode.mask)
+	}
+	return s
+}
+
+// json returns body as is if nothing is redacted, since the keys are sorted by marshaling
+func (r *redactor) json(body string) string {
+	d := json.NewDecoder(strings.NewReader(body))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil || d.More() {
+		return r.jsonFallback(body)
+	}
+	redacted := false
+	for _, p := range r.paths {
+		v = redactJSON(v, p.segments, p.mode, &redacted)
+	}
+	if !redacted {
+		return body
+	}
+
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return body
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// jsonFallback masks the values of the last keys of the paths regardless of the parents
+func (r *redactor) jsonFallback(body string) string {
+	for _, p := range r.paths {
+		if p.fallback == nil {
+			continue
+		}
+		body = p.fallback.ReplaceAllStringFunc(body, func(s string) string {
+			m := p.fallback.FindStringSubmatchIndex(s)
+			value := strings.Trim(s[m[2]:m[3]], `"`)
+			return s[:m[2]] + `"` + p.mode.mask(value) + `"`
+		})
+	}
+	return body
+}
+
+func redactJSON(v any, segments []string, mode RedactMode, redacted *bool) any {
+	switch val := v.(type) {
+	case []any:
+		for i := range val {
+			val[i] = redactJSON(val[i], segments, mode, redacted)
+		}
+		return val
+	case map[string]any:
+		if len(segments) == 0 {
+			break
+		}
+		for key, child := range val {
+			if segments[0] != "*" && segments[0] != key {
+				continue
+			}
+			if len(segments) == 1 {
+				val[key] = maskJSON(child, mode)
+				*redacted = true
+			} else {
+				val[key] = redactJSON(child, segments[1:], mode, redacted)
+			}
+		}
+		return val
+	}
+	return v
+}
+
+func maskJSON(v any, mode RedactMode) string {
+	if s, ok := v.(string); ok {
+		return mode.mask(s)
+	}
+	b, _ := json.Marshal(v)
+	return mode.mask(string(b))
+}
@@ -0,0 +1,111 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactMode(t *testing.T) {
+	require.Equal(t, "******", RedactFull.mask("secret"))
+	require.Equal(t, "Bear******1c2d", RedactPartial.mask("Bearer eyJhbGci.x1c2d"))
+	require.Equal(t, "s******t", RedactPartial.mask("secret"))
+	require.Equal(t, "******", RedactPartial.mask("abc"))
+	require.Equal(t, RedactHash.mask("secret"), RedactHash.mask("secret"))
+	require.True(t, strings.HasPrefix(RedactHash.mask("secret"), "sha256:"))
+	require.NotContains(t, RedactHash.mask("secret"), "secret")
+}
+
+func TestRedactor(t *testing.T) {
+	_, err := newRedactor(&RedactConfig{Rules: []RedactRule{{Regex: "("}}})
+	require.Error(t, err)
+	_, err = newRedactor(&RedactConfig{Rules: []RedactRule{{Header: "X-Token", Mode: "unknown"}}})
+	require.Error(t, err)
+	_, err = newRedactor(&RedactConfig{Rules: []RedactRule{{}}})
+	require.Error(t, err)
+	r, err := newRedactor(&RedactConfig{Disable: true})
+	require.NoError(t, err)
+	require.Equal(t, "Bearer t", r.header(echo.HeaderAuthorization, "Bearer t"))
+
+	r, err = newRedactor(&RedactConfig{
+		Mode: RedactFull,
+		Rules: []RedactRule{
+			{Header: "authorization", Mode: RedactPartial},
+			{Query: "token"},
+			{JSONPath: "password"},
+			{JSONPath: "items.*.secret", Mode: RedactHash},
+			{Regex: `\d{4}-\d{4}-\d{4}-\d{4}`},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "Bear******1234", r.header(echo.HeaderAuthorization, "Bearer abcdef1234"))
+	require.Equal(t, "******", r.header(echo.HeaderCookie, "sid=1"))
+	require.Equal(t, "v1", r.header("X-Request-Id", "v1"))
+
+	h := http.Header{"Cookie": {"sid=1"}, "X-Trace": {"t1"}}
+	redacted := r.headers(h)
+	require.Equal(t, []string{"******"}, redacted["Cookie"])
+	require.Equal(t, []string{"t1"}, redacted["X-Trace"])
+	require.Equal(t, []string{"sid=1"}, h["Cookie"])
+
+	require.Equal(t, "/v1/a?b=1&token=%2A%2A%2A%2A%2A%2A&c", r.uri("/v1/a?b=1&token=abc&c"))
+	require.Equal(t, "/v1/cards/******", r.uri("/v1/cards/1234-5678-9012-3456"))
+
+	require.Equal(t, `{"name":"n","password":"******"}`,
+		r.body(echo.MIMEApplicationJSON, `{"password":"p","name":"n"}`))
+	// truncated or NDJSON
+	require.Equal(t, `{"user":{"password": "******", "id": 1`, r.body(echo.MIMEApplicationJSON, `{"user":{"password": 12, "id": 1`))
+	require.Equal(t, `{"password":"******"}`+"\n"+`{"password":"******"`,
+		r.body("application/x-ndjson", `{"password":"p\"1"}`+"\n"+`{"password":"p2...`))
+	require.Equal(t, `{"name":"n"}`, r.body(echo.MIMEApplicationJSON, `{"name":"n"}`))
+	require.Equal(t, `password=p&token=%2A%2A%2A%2A%2A%2A`, r.body(echo.MIMEApplicationForm, `password=p&token=t`))
+	body := r.body(echo.MIMEApplicationJSON, `{"items":[{"a":{"secret":"s1"}},{"b":{"secret":"s1"}}]}`)
+	require.NotContains(t, body, `"s1"`)
+	require.Equal(t, 2, strings.Count(body, RedactHash.mask("s1")))
+}
+
+func TestApiGatewayInvalidRedact(t *testing.T) {
+	for _, rule := range []RedactRule{{Regex: "("}, {Header: "X-Token", Mode: "unknown"}} {
+		_, err := NewApiGateway(context.Background(), "127.0.0.1", "0", "test", &LogConfig{
+			LogFile: log.FileConfig{Filename: "discard"},
+			Level:   "info",
+			Redact:  RedactConfig{Rules: []RedactRule{rule}},
+		}, nil)
+		require.Error(t, err)
+	}
+}
+
+func TestAccessLogRedact(t *testing.T) {
+	var out bytes.Buffer
+	agw := newTestApiGateway(t)
+	agw.LogConf.ContentFormatAfter = "${uri} ${header_in:Authorization} ${cookie:sid} ${query:token} ${body_in} ${body_out}"
+	agw.LogConf.Timing = AccessLogAfterRun
+	agw.LogConf.Redact = RedactConfig{Rules: []RedactRule{{Query: "token"}, {JSONPath: "password"}, {JSONPath: "Result.password"}}}
+	agw.LogConf.BodyBufferSize = 1024
+	agw.Logger.Out = &out
+	agw.configEcho()
+	agw.POST("/v1/login", func(c echo.Context) error {
+		return SendResp(c, SuccessResp(map[string]string{"password": "p2"}))
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/login?token=t1", strings.NewReader(`{"password":"p1"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer xyz")
+	req.Header.Set(echo.HeaderCookie, "sid=s1")
+	serveTestRequest(agw, req)
+
+	line := out.String()
+	require.Contains(t, line, "/v1/login?token=%2A%2A%2A%2A%2A%2A ****** ****** ****** ")
+	require.Contains(t, line, `in[21]:{"password":"******"}`)
+	for _, secret := range []string{"t1", "xyz", "s1", "p1", "p2"} {
+		require.NotContains(t, line, secret)
+	}
+}
@@ -0,0 +1,142 @@
+package httpx
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	AccessLogReasonError = "error"
+	AccessLogReasonSlow  = "slow"
+
+	// DefaultAccessLogDetailFormat is the full-detail line of the requests always logged
+	DefaultAccessLogDetailFormat = "${time_custom} DETAIL ${id} ${status} ${method} ${uri} ${latency_human} " +
+		"reason:${log_reason} headers_in:${headers_in} headers_out:${headers_out} ${body_in} ${body_out}"
+)
+
+// AccessLogSampling defines which requests are logged, e.g.
+//
+//	[Http.Log.Sampling]
+//	Enable = true
+//	Rate = 0.01
+//	AlwaysLogStatus = "5xx"
+//	SlowThreshold = "1s"
+//	[[Http.Log.Sampling.Rules]]
+//	Path = "/v1/orders"
+//	Rate = 1
+type AccessLogSampling struct {
+	// Enable turns on the sampling, all the requests are logged if false
+	Enable bool
+	// Rate is the ratio of the requests logged, from 0 to 1
+	Rate float64 `vx_default:"1"`
+	// Rules override Rate by route and status, the first matched wins
+	Rules []AccessLogSamplingRule
+	// AlwaysLogStatus is a comma separated list of status, e.g. 5xx,429, always logged with details
+	AlwaysLogStatus string `vx_default:"5xx"`
+	// SlowThreshold logs the requests slower than it with details, disabled if 0
+	SlowThreshold time.Duration `vx_default:"0s"`
+	// DisableDetail skips the full-detail line of the requests always logged
+	DisableDetail bool
+}
+
+// AccessLogSamplingRule is the sampling rate of the matched requests
+type AccessLogSamplingRule struct {
+	// Path is the prefix of the request path, any if empty
+	Path string
+	// Method is any if empty
+	Method string
+	// Status is a comma separated list of status, e.g. 2xx,404, any if empty
+	Status string
+	Rate   float64
+}
+
+// accessLogSampler decides once for each request by a random number, so that the before and after
+// lines of a request are logged together unless the rate depends on status
+type accessLogSampler struct {
+	config AccessLogSampling
+	always []string
+	rules  []accessLogSamplingRule
+	rand   func() float64
+}
+
+type accessLogSamplingRule struct {
+	AccessLogSamplingRule
+	status []string
+}
+
+func splitStatus(s string) []string {
+	var status []string
+	for _, st := range strings.Split(s, ",") {
+		if st = strings.ToLower(strings.TrimSpace(st)); st != "" {
+			status = append(status, st)
+		}
+	}
+	return status
+}
+
+// matchStatus reports whether status is one of patterns, e.g. 5xx or 404
+func matchStatus(patterns []string, status int) bool {
+	s := strconv.Itoa(status)
+	for _, p := range patterns {
+		if len(p) == 3 && strings.HasSuffix(p, "xx") && len(s) == 3 && s[0] == p[0] {
+			return true
+		}
+		if p == s {
+			return true
+		}
+	}
+	return false
+}
+
+func newAccessLogSampler(config *AccessLogSampling) *accessLogSampler {
+	if config == nil || !config.Enable {
+		return nil
+	}
+	s := &accessLogSampler{config: *config, always: splitStatus(config.AlwaysLogStatus), rand: rand.Float64}
+	for _, r := range config.Rules {
+		s.rules = append(s.rules, accessLogSamplingRule{AccessLogSamplingRule: r, status: splitStatus(r.Status)})
+	}
+	return s
+}
+
+// rate returns the rate of the request, the rules depending on status are skipped if status is 0
+func (s *accessLogSampler) rate(c echo.Context, status int) float64 {
+	req := c.Request()
+	for _, r := range s.rules {
+		if r.Path != "" && !pathHasPrefix(req.URL.Path, r.Path) {
+			continue
+		}
+		if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+			continue
+		}
+		if len(r.status) > 0 && (status == 0 || !matchStatus(r.status, status)) {
+			continue
+		}
+		return r.Rate
+	}
+	return s.config.Rate
+}
+
+func (s *accessLogSampler) roll() float64 {
+	return s.rand()
+}
+
+// sampledBefore reports whether the line before run is logged
+func (s *accessLogSampler) sampledBefore(c echo.Context, roll float64) bool {
+	return roll < s.rate(c, 0)
+}
+
+// sampledAfter reports whether the line after run is logged, and the reason if it is always logged
+func (s *accessLogSampler) sampledAfter(c echo.Context, status int, latency time.Duration, roll float64) (bool, string) {
+	if matchStatus(s.always, status) {
+		return true, AccessLogReasonError
+	}
+	if s.config.SlowThreshold > 0 && latency >= s.config.SlowThreshold {
+		return true, AccessLogReasonSlow
+	}
+	return roll < s.rate(c, status), ""
+}
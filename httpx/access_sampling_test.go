@@ -0,0 +1,133 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogSamplerRate(t *testing.T) {
+	require.True(t, matchStatus(splitStatus("5xx, 429"), http.StatusBadGateway))
+	require.True(t, matchStatus(splitStatus("5XX,429"), http.StatusTooManyRequests))
+	require.False(t, matchStatus(splitStatus("5xx,429"), http.StatusNotFound))
+	require.Nil(t, newAccessLogSampler(&AccessLogSampling{Rate: 1}))
+
+	s := newAccessLogSampler(&AccessLogSampling{
+		Enable: true,
+		Rate:   0.1,
+		Rules: []AccessLogSamplingRule{
+			{Path: "/v1/orders", Method: http.MethodPost, Rate: 1},
+			{Path: "/v1/orders", Status: "4xx", Rate: 0.5},
+			{Path: "/v1/orders", Rate: 0},
+		},
+	})
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/v1/orders/1", nil), nil)
+	require.Equal(t, 1.0, s.rate(c, 0))
+	c = echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/v1/orders", nil), nil)
+	require.Equal(t, 0.0, s.rate(c, 0))
+	require.Equal(t, 0.5, s.rate(c, http.StatusNotFound))
+	c = echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/v1/ordersx", nil), nil)
+	require.Equal(t, 0.1, s.rate(c, http.StatusOK))
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	var out bytes.Buffer
+	agw := newTestApiGateway(t)
+	agw.LogConf.ContentFormatBefore = "BEF ${path}"
+	agw.LogConf.ContentFormatAfter = "AFT ${path} ${status}"
+	agw.LogConf.Sampling = AccessLogSampling{
+		Enable:          true,
+		AlwaysLogStatus: "5xx",
+		SlowThreshold:   20 * time.Millisecond,
+		Rules:           []AccessLogSamplingRule{{Path: "/v1/keep", Rate: 1}},
+	}
+	agw.Logger.Out = &out
+	agw.configEcho()
+	handler := func(c echo.Context) error { return c.String(http.StatusOK, "ok") }
+	agw.GET("/v1/drop", handler)
+	agw.GET("/v1/keep", handler)
+	agw.GET("/v1/fail", func(c echo.Context) error { return c.String(http.StatusInternalServerError, "boom") })
+	agw.GET("/v1/slow", func(c echo.Context) error {
+		time.Sleep(30 * time.Millisecond)
+		return handler(c)
+	})
+
+	serve := func(path string) string {
+		out.Reset()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Trace", "t1")
+		serveTestRequest(agw, req)
+		return out.String()
+	}
+
+	require.Empty(t, serve("/v1/drop"))
+	require.Equal(t, "BEF /v1/keep\nAFT /v1/keep 200\n", serve("/v1/keep"))
+
+	lines := strings.Split(strings.TrimSpace(serve("/v1/fail")), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, "AFT /v1/fail 500", lines[0])
+	require.Contains(t, lines[1], " DETAIL ")
+	require.Contains(t, lines[1], "reason:error")
+	require.Contains(t, lines[1], `"X-Trace":["t1"]`)
+
+	lines = strings.Split(strings.TrimSpace(serve("/v1/slow")), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, "AFT /v1/slow 200", lines[0])
+	require.Contains(t, lines[1], "reason:slow")
+}
+
+func TestAccessLogSamplingJSON(t *testing.T) {
+	var out bytes.Buffer
+	agw := newTestApiGateway(t)
+	agw.LogConf.Mode = AccessLogJSON
+	agw.LogConf.Fields = []string{"path", "status"}
+	agw.LogConf.Sampling = AccessLogSampling{Enable: true, AlwaysLogStatus: "5xx"}
+	agw.Logger.Out = &out
+	agw.configEcho()
+	agw.GET("/v1/ok", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	agw.GET("/v1/fail", func(c echo.Context) error { return c.NoContent(http.StatusBadGateway) })
+
+	serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/v1/ok", nil))
+	require.Empty(t, out.String())
+
+	serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/v1/fail", nil))
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry), out.String())
+	require.Equal(t, "/v1/fail", entry["path"])
+	require.Equal(t, float64(http.StatusBadGateway), entry["status"])
+	require.Equal(t, AccessLogReasonError, entry["log_reason"])
+	require.Contains(t, entry, "headers_in")
+	require.Contains(t, entry, "headers_out")
+}
+
+func TestLogConfigLoad(t *testing.T) {
+	var cfg struct {
+		LogLoad struct {
+			Log LogConfig
+		}
+	}
+	loadTestConfig(t, &cfg, `
+[LogLoad.Log]
+Mode = "json"
+[LogLoad.Log.Sampling]
+Enable = true
+Rate = 0.1
+[LogLoad.Log.Redact]
+Mode = "partial"
+`)
+	lc := cfg.LogLoad.Log
+	require.Equal(t, AccessLogJSON, lc.Mode)
+	require.Equal(t, AccessLogBoth, lc.Timing)
+	require.True(t, lc.Sampling.Enable)
+	require.Equal(t, 0.1, lc.Sampling.Rate)
+	require.Equal(t, "5xx", lc.Sampling.AlwaysLogStatus)
+	require.Zero(t, lc.Sampling.SlowThreshold)
+	require.Equal(t, RedactPartial, lc.Redact.Mode)
+}
@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
 	labstacklog "github.com/labstack/gommon/log"
+	"github.com/madlabx/pkgx/errcode_if"
+	"github.com/madlabx/pkgx/errors"
 	"github.com/madlabx/pkgx/log"
+	"github.com/madlabx/pkgx/metricx"
 	"github.com/sirupsen/logrus"
 )
 
@@ -23,6 +25,16 @@ type LogConfig struct {
 	Level          string          `vx_default:"info"`
 	Timing         AccessLogTiming `vx_default:"both"`
 	BodyBufferSize int64           `vx_default:"4096"`
+	// PrintableContentTypes are the content types of the bodies logged, DefaultPrintableContentTypes if empty
+	PrintableContentTypes []string
+	// Mode is template, fields to log the access logs as fields of Logger, or json for JSON lines
+	Mode AccessLogMode `vx_default:"template"`
+	// Fields are the tags logged by fields and json modes, DefaultAccessLogFields if empty
+	Fields []string
+	// Sampling logs a part of the requests, and the failed or slow ones with details
+	Sampling AccessLogSampling
+	// Redact masks the sensitive values before logging, Authorization and Cookie by default
+	Redact RedactConfig
 	// Tags to construct the Logger format.
 	//
 	// - time_unix
@@ -39,6 +51,7 @@ type LogConfig struct {
 	// - protocol
 	// - referer
 	// - user_agent
+	// - client_cn (CommonName of client certificate)
 	// - status
 	// - error
 	// - latency (In nanoseconds)
@@ -51,6 +64,9 @@ type LogConfig struct {
 	// - form:<NAME>
 	// - body_in (request body)
 	// - body_out (response body)
+	// - headers_in (request headers in JSON)
+	// - headers_out (response headers in JSON)
+	// - log_reason (error or slow, why the request is always logged by Sampling)
 	//ContentFormatBefore string `vx_default:"${time_custom} BEF ${method} ${uri} ${host} ${remote_ip} ${bytes_in}"`
 	ContentFormatBefore string
 	//ContentFormatAfter  string `vx_default:"${time_custom} AFT ${status} ${method} ${latency_human} ${uri} ${host} ${remote_ip} ${bytes_in} ${bytes_out} ${error}"`
@@ -60,14 +76,35 @@ type LogConfig struct {
 type ApiGateway struct {
 	ctx context.Context
 	*echo.Echo
-	addr              string
-	port              string
-	name              string
-	Logger            *log.Logger
-	LogConf           *LogConfig
-	EntryFormat       logrus.Formatter
-	loggerSkipper     middleware.Skipper
-	bodyLoggerSkipper middleware.Skipper
+	addr               string
+	port               string
+	name               string
+	Logger             *log.Logger
+	LogConf            *LogConfig
+	EntryFormat        logrus.Formatter
+	loggerSkipper      middleware.Skipper
+	bodyLoggerSkipper  middleware.Skipper
+	tlsConf            *TLSConfig
+	corsConf           *CORSConfig
+	groupCORSConf      map[string]*CORSConfig
+	metricsConf        *MetricsConfig
+	metricsReg         *metricx.Registry
+	healthConf         *HealthConfig
+	healthReg          *HealthRegistry
+	rateLimitPolicy    *rateLimitPolicy
+	groupRateLimit     map[string]*rateLimitPolicy
+	openAPIConf        *OpenAPIConfig
+	openAPIPaths       map[string]bool
+	routeDocs          map[string]*RouteDoc
+	groupConfs         map[string]*GroupConfig
+	shutdownConf       *ShutdownConfig
+	limits             requestLimits
+	groupLimits        map[string]requestLimits
+	compressPolicy     *compressPolicy
+	groupCompress      map[string]*compressPolicy
+	drainer            *drainer
+	errCodeDic         errcode_if.ErrorCodeDictionaryIf
+	requestIdGenerator func() string
 }
 
 func NewApiGateway(pCtx context.Context, addr, port, name string, lc *LogConfig, logFormat logrus.Formatter) (*ApiGateway, error) {
@@ -79,7 +116,11 @@ func NewApiGateway(pCtx context.Context, addr, port, name string, lc *LogConfig,
 		Echo:        echo.New(),
 		LogConf:     lc,
 		EntryFormat: logFormat,
+		healthReg:   NewHealthRegistry(),
+		drainer:     newDrainer(),
 	}
+	// readiness fails as soon as pCtx is cancelled, e.g. graceful.Graceful.Context()
+	agw.healthReg.WatchContext(pCtx)
 
 	//if lc == nil, log to log.StandardLogger
 	if err := agw.initAccessLog(); err != nil {
@@ -89,6 +130,26 @@ func NewApiGateway(pCtx context.Context, addr, port, name string, lc *LogConfig,
 	return agw, nil
 }
 
+// SetErrCodeDictionary overrides the dictionary of RegisterErrCodeDictionary for this ApiGateway,
+// the codes of SuccessResp, BadRequestResp and InternalErrorResp are resolved by it in SendResp
+func (agw *ApiGateway) SetErrCodeDictionary(dic errcode_if.ErrorCodeDictionaryIf) {
+	agw.errCodeDic = dic
+}
+
+// errCodeDictionary returns the dictionary of SetErrCodeDictionary, or the package dictionary
+func (agw *ApiGateway) errCodeDictionary() errcode_if.ErrorCodeDictionaryIf {
+	if agw.errCodeDic != nil {
+		return agw.errCodeDic
+	}
+	return errCodeDic
+}
+
+// SetRequestIdGenerator mints the request ids of this ApiGateway instead of the NewRequestId of
+// the dictionary
+func (agw *ApiGateway) SetRequestIdGenerator(f func() string) {
+	agw.requestIdGenerator = f
+}
+
 func (agw *ApiGateway) SetLoggerSkipper(s middleware.Skipper) {
 	agw.loggerSkipper = s
 }
@@ -97,22 +158,106 @@ func (agw *ApiGateway) SetBodyLoggerSkipper(s middleware.Skipper) {
 	agw.bodyLoggerSkipper = s
 }
 
+// SetTLSConfig serves https instead of http if tc.Enabled()
+func (agw *ApiGateway) SetTLSConfig(tc *TLSConfig) {
+	agw.tlsConf = tc
+}
+
+// SetCORSConfig replaces DefaultCORSConfig, cc.Disable turns CORS off
+func (agw *ApiGateway) SetCORSConfig(cc *CORSConfig) {
+	agw.corsConf = cc
+}
+
+// SetGroupCORSConfig overrides the CORS policy for requests under prefix
+func (agw *ApiGateway) SetGroupCORSConfig(prefix string, cc *CORSConfig) {
+	if agw.groupCORSConf == nil {
+		agw.groupCORSConf = make(map[string]*CORSConfig)
+	}
+	agw.groupCORSConf[prefix] = cc
+}
+
+// SetMetricsConfig serves metrics on mc.Path if mc.Enable
+func (agw *ApiGateway) SetMetricsConfig(mc *MetricsConfig) {
+	agw.metricsConf = mc
+}
+
+// SetMetricsRegistry replaces the registry created by the gateway, e.g. with metricx.Default()
+func (agw *ApiGateway) SetMetricsRegistry(reg *metricx.Registry) {
+	agw.metricsReg = reg
+}
+
+// MetricsRegistry returns the registry exposed on MetricsConfig.Path, JsonClient and
+// grpcpool could register into it as well.
+func (agw *ApiGateway) MetricsRegistry() *metricx.Registry {
+	if agw.metricsReg == nil {
+		agw.metricsReg = metricx.NewRegistry()
+	}
+	return agw.metricsReg
+}
+
+// SetHealthConfig serves liveness and readiness on hc.LivenessPath and hc.ReadinessPath if hc.Enable
+func (agw *ApiGateway) SetHealthConfig(hc *HealthConfig) {
+	agw.healthConf = hc
+}
+
+// HealthRegistry returns the registry served by the gateway, readiness fails once
+// the context passed to NewApiGateway is done or Stop is called
+func (agw *ApiGateway) HealthRegistry() *HealthRegistry {
+	return agw.healthReg
+}
+
+// SetRateLimitConfig limits the requests of all routes, overridden by SetGroupRateLimitConfig.
+// It fails if rc is invalid.
+func (agw *ApiGateway) SetRateLimitConfig(rc *RateLimitConfig) error {
+	if rc == nil {
+		agw.rateLimitPolicy = nil
+		return nil
+	}
+	p, err := newRateLimitPolicy(*rc)
+	if err != nil {
+		return err
+	}
+	agw.rateLimitPolicy = p
+	return nil
+}
+
+// SetGroupRateLimitConfig limits the requests under path prefix with a dedicated store,
+// Limit 0 exempts the group from the gateway limit. It fails if rc is invalid.
+func (agw *ApiGateway) SetGroupRateLimitConfig(prefix string, rc *RateLimitConfig) error {
+	p, err := newRateLimitPolicy(*rc)
+	if err != nil {
+		return errors.Wrapf(err, "rate limit of group %v", prefix)
+	}
+	if agw.groupRateLimit == nil {
+		agw.groupRateLimit = make(map[string]*rateLimitPolicy)
+	}
+	agw.groupRateLimit[prefix] = p
+	return nil
+}
+
 func (agw *ApiGateway) Name() string {
 	return agw.name
 }
 func (agw *ApiGateway) Run() error {
 	agw.configEcho()
-	return agw.startEcho(fmt.Sprintf("%s:%s", agw.addr, agw.port))
+	addr := fmt.Sprintf("%s:%s", agw.addr, agw.port)
+	if agw.tlsConf.Enabled() {
+		return agw.startEchoTLS(addr)
+	}
+	return agw.startEcho(addr)
 }
 
+// Stop drains the in-flight requests by ShutdownConfig, see Shutdown
 func (agw *ApiGateway) Stop() error {
-	return agw.shutdownEcho()
+	_, err := agw.Shutdown(agw.ctx)
+	return err
 }
 
 func (agw *ApiGateway) initAccessLog() error {
 	if agw.LogConf == nil {
 		agw.LogConf = &LogConfig{}
-		agw.Logger = log.StandardLogger()
+		// a logger of its own to not change the level and formatter of the standard logger
+		agw.Logger = log.NewLogger(agw.ctx, log.FileConfig{Filename: "main"})
 	} else {
 		agw.Logger = log.NewLogger(agw.ctx, agw.LogConf.LogFile)
 	}
@@ -123,6 +268,13 @@ func (agw *ApiGateway) initAccessLog() error {
 	}
 	agw.Logger.SetLevel(level)
 
+	if err := agw.LogConf.Mode.validate(); err != nil {
+		return err
+	}
+	if _, err := newRedactor(&agw.LogConf.Redact); err != nil {
+		return err
+	}
+
 	// Set body format
 	if agw.EntryFormat == nil {
 		agw.EntryFormat = &log.TextFormatter{QuoteEmptyFields: true}
@@ -160,25 +312,92 @@ func (agw *ApiGateway) configEcho() {
 	if agw.bodyLoggerSkipper != nil {
 		bodyFilter = agw.bodyLoggerSkipper
 	}
+	outBodyFilter := func(c echo.Context) bool {
+		if gc := agw.groupConfOf(c.Request().URL.Path); gc != nil && gc.DisableBodyLog {
+			return false
+		}
+		return bodyFilter(c)
+	}
+	inBodySkipper := func(c echo.Context) bool {
+		gc := agw.groupConfOf(c.Request().URL.Path)
+		return gc != nil && gc.DisableBodyLog
+	}
+	loggerSkipper := func(c echo.Context) bool {
+		if gc := agw.groupConfOf(c.Request().URL.Path); gc != nil && gc.DisableLog {
+			return true
+		}
+		return agw.loggerSkipper != nil && agw.loggerSkipper(c)
+	}
+	if dic := agw.errCodeDic; dic != nil {
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				c.SetRequest(c.Request().WithContext(ContextWithErrCodeDictionary(c.Request().Context(), dic)))
+				return next(c)
+			}
+		})
+	}
+	e.Use(RequestIdWithConfig(RequestIdConfig{Generator: agw.requestIdGenerator}))
+	e.Use(agw.drainer.middleware())
+	if mc := agw.metricsConf; mc != nil && mc.Enable {
+		namespace, path := mc.Namespace, mc.Path
+		if namespace == "" {
+			namespace = "httpx"
+		}
+		if path == "" {
+			path = "/metrics"
+		}
+		e.Use(newServerMetrics(agw.MetricsRegistry(), namespace).middleware())
+		e.GET(path, echo.WrapHandler(agw.MetricsRegistry()))
+	}
+	// outside Logger to log the plaintext body_out
+	e.Use(agw.compressMiddleware())
 	e.Use(LoggerWithConfig(LoggerConfig{
-		OutBodyFilter:    bodyFilter,
-		FormatAfter:      agw.LogConf.ContentFormatAfter,
-		FormatBefore:     agw.LogConf.ContentFormatBefore,
-		CustomTimeFormat: "2006/01/02 15:04:05.000",
-		Output:           agw.Logger.Out,
-		bodyBufferSize:   agw.LogConf.BodyBufferSize,
-		Timing:           agw.LogConf.Timing,
-		Skipper:          agw.loggerSkipper,
+		OutBodyFilter:         outBodyFilter,
+		InBodySkipper:         inBodySkipper,
+		FormatAfter:           agw.LogConf.ContentFormatAfter,
+		FormatBefore:          agw.LogConf.ContentFormatBefore,
+		CustomTimeFormat:      "2006/01/02 15:04:05.000",
+		Output:                agw.Logger.Out,
+		bodyBufferSize:        agw.LogConf.BodyBufferSize,
+		PrintableContentTypes: agw.LogConf.PrintableContentTypes,
+		Timing:                agw.LogConf.Timing,
+		Mode:                  agw.LogConf.Mode,
+		Fields:                agw.LogConf.Fields,
+		FieldLogger:           agw.Logger,
+		Sampling:              &agw.LogConf.Sampling,
+		Redact:                &agw.LogConf.Redact,
+		Skipper:               loggerSkipper,
 	}))
 
-	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins:     []string{"*"},
-		ExposeHeaders:    []string{"*"},
-		AllowMethods:     []string{"*"},
-		AllowHeaders:     []string{"*"},
-		AllowCredentials: true,
-		//AllowMethods: []string{Echo.GET, Echo.PUT, Echo.POST, Echo.DELETE},
-	}))
+	e.Use(RecoverWithConfig(RecoverConfig{Logger: agw.Logger}))
+
+	e.Use(agw.corsMiddleware())
+
+	e.Use(agw.rateLimitMiddleware())
+	e.Use(agw.limitMiddleware())
+
+	if hc := agw.healthConf; hc != nil && hc.Enable {
+		livenessPath, readinessPath := hc.LivenessPath, hc.ReadinessPath
+		if livenessPath == "" {
+			livenessPath = "/healthz"
+		}
+		if readinessPath == "" {
+			readinessPath = "/readyz"
+		}
+		e.GET(livenessPath, agw.healthReg.handler(false))
+		e.GET(readinessPath, agw.healthReg.handler(true))
+	}
+
+	if oc := agw.openAPIConf; oc != nil && oc.Enable {
+		path := oc.Path
+		if path == "" {
+			path = "/openapi"
+		}
+		jsonHandler, yamlHandler := agw.openAPIHandlers()
+		e.GET(path+".json", jsonHandler)
+		e.GET(path+".yaml", yamlHandler)
+		agw.openAPIPaths = map[string]bool{path + ".json": true, path + ".yaml": true}
+	}
 
 	//TODO 检查是否可以恢复。不注释回无法下载css
 	//e.Use(func(next Echo.HandlerFunc) Echo.HandlerFunc {
@@ -193,10 +412,21 @@ func (agw *ApiGateway) startEcho(addr string) error {
 	return agw.Echo.Start(addr)
 }
 
-func (agw *ApiGateway) shutdownEcho() error {
-	ctx, cancel := context.WithTimeout(agw.ctx, 5*time.Second)
-	defer cancel()
-	return agw.Echo.Shutdown(ctx)
+func (agw *ApiGateway) startEchoTLS(addr string) error {
+	nextProtos := []string{"http/1.1"}
+	if !agw.Echo.DisableHTTP2 {
+		nextProtos = []string{"h2", "http/1.1"}
+	}
+
+	cr, err := newCertReloader(agw.tlsConf, nextProtos)
+	if err != nil {
+		return err
+	}
+
+	s := agw.Echo.TLSServer
+	s.Addr = addr
+	s.TLSConfig = cr.TLSConfig()
+	return agw.Echo.StartServer(s)
 }
 
 func (agw *ApiGateway) RoutesToString() string {
@@ -0,0 +1,84 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/errcode_if"
+	"github.com/stretchr/testify/require"
+)
+
+type testErrCodeDic struct {
+	errcode_if.DefaultErrCodeDic
+	prefix string
+	seq    atomic.Int64
+}
+
+func (d *testErrCodeDic) GetSuccess() errcode_if.ErrorCodeIf {
+	return &errcode_if.DefaultErrCode{Code: d.prefix + "Success", Status: http.StatusOK, Errno: 0}
+}
+
+func (d *testErrCodeDic) ToCode(status int) string {
+	return d.prefix + d.DefaultErrCodeDic.ToCode(status)
+}
+
+func (d *testErrCodeDic) NewRequestId() string {
+	return fmt.Sprintf("%v-%v", d.prefix, d.seq.Add(1))
+}
+
+func TestMultipleApiGateways(t *testing.T) {
+	newGateway := func(prefix string, out *bytes.Buffer, generator func() string) *ApiGateway {
+		agw := newTestApiGateway(t)
+		agw.LogConf.ContentFormatAfter = prefix + " ${id} ${status}"
+		agw.LogConf.Timing = AccessLogAfterRun
+		agw.Logger.Out = out
+		agw.SetErrCodeDictionary(&testErrCodeDic{prefix: prefix})
+		agw.SetRequestIdGenerator(generator)
+		agw.configEcho()
+		agw.GET("/v1/ok", func(c echo.Context) error { return SendResp(c, SuccessResp("ok")) })
+		agw.GET("/v1/busy", func(c echo.Context) error {
+			return SendResp(c, newErrResp(http.StatusServiceUnavailable, ""))
+		})
+		return agw
+	}
+	var publicOut, adminOut bytes.Buffer
+	public := newGateway("public", &publicOut, nil)
+	admin := newGateway("admin", &adminOut, func() string { return "admin-fixed" })
+
+	body := func(rec *httptest.ResponseRecorder) map[string]any {
+		var m map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m), rec.Body.String())
+		return m
+	}
+
+	t.Run("public", func(t *testing.T) {
+		t.Parallel()
+		rec := serveTestRequest(public, httptest.NewRequest(http.MethodGet, "/v1/ok", nil))
+		require.Equal(t, "publicSuccess", body(rec)["Code"])
+		require.True(t, strings.HasPrefix(rec.Header().Get(echo.HeaderXRequestID), "public-"))
+
+		rec = serveTestRequest(public, httptest.NewRequest(http.MethodGet, "/v1/busy", nil))
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		require.Equal(t, "publicServiceUnavailable", body(rec)["Code"])
+	})
+	t.Run("admin", func(t *testing.T) {
+		t.Parallel()
+		rec := serveTestRequest(admin, httptest.NewRequest(http.MethodGet, "/v1/ok", nil))
+		require.Equal(t, "adminSuccess", body(rec)["Code"])
+		require.Equal(t, "admin-fixed", rec.Header().Get(echo.HeaderXRequestID))
+	})
+	t.Cleanup(func() {
+		require.Contains(t, publicOut.String(), "public public-")
+		require.NotContains(t, publicOut.String(), "admin")
+		require.Equal(t, "admin admin-fixed 200\n", adminOut.String())
+		// the package dictionary is untouched
+		require.Equal(t, "OK", SuccessResp(nil).Code)
+	})
+}
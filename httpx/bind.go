@@ -55,20 +55,25 @@ hx_tag自定义如下：
 		f5: same to hx_range
 */
 func BindAndValidate(c echo.Context, i any) error {
+	return BindAndValidateRequest(c.Request(), i)
+}
+
+// BindAndValidateRequest is BindAndValidate for net/http, e.g. in plain http.Handler or tests
+func BindAndValidateRequest(req *http.Request, i any) error {
 	hp := new(hxParser)
 	hp.bodyMap = make(map[string]any)
-	hp.queryParams = c.QueryParams()
-	hp.headers = c.Request().Header
+	hp.queryParams = req.URL.Query()
+	hp.headers = req.Header
 
-	if c.Request().ContentLength > 0 &&
-		strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+	if req.ContentLength > 0 &&
+		strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
 		// Request
 		var reqBody []byte
-		if c.Request().Body != nil { // Read
-			reqBody, _ = io.ReadAll(c.Request().Body)
+		if req.Body != nil { // Read
+			reqBody, _ = io.ReadAll(req.Body)
 		}
 
-		c.Request().Body = io.NopCloser(bytes.NewBuffer(reqBody)) // Reset
+		req.Body = io.NopCloser(bytes.NewBuffer(reqBody)) // Reset
 
 		decoder := json.NewDecoder(bytes.NewBuffer(reqBody))
 		decoder.UseNumber()
@@ -0,0 +1,378 @@
+package httpx
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/madlabx/pkgx/errors"
+)
+
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+
+	defaultCompressMinLength = 1024
+)
+
+// DefaultCompressExcludedContentTypes are compressed already or streamed
+var DefaultCompressExcludedContentTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-7z-compressed",
+	"application/x-rar-compressed", "application/x-bzip2", "application/x-xz", "application/zstd",
+	"application/pdf", "application/octet-stream", "text/event-stream",
+}
+
+// CompressConfig defines the config for Compress middleware.
+type CompressConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper `json:"-" mapstructure:"-"`
+
+	// Disable turns off the compression, e.g. of a group when it is enabled for the gateway
+	Disable bool
+	// Level of gzip and deflate, from 1 (best speed) to 9 (best compression), default compression if 0,
+	// -1 and -2 are gzip.DefaultCompression and gzip.HuffmanOnly
+	Level int
+	// MinLength is the min size of the response body to be compressed
+	MinLength int `vx_default:"1024"`
+	// ExcludedContentTypes are the prefixes of the content types not compressed,
+	// DefaultCompressExcludedContentTypes if empty
+	ExcludedContentTypes []string
+}
+
+// compressor holds the pools of writers of an encoding
+type compressor struct {
+	encoding string
+	pool     sync.Pool
+}
+
+func newCompressor(encoding string, level int) *compressor {
+	cp := &compressor{encoding: encoding}
+	cp.pool.New = func() any {
+		var (
+			w   io.WriteCloser
+			err error
+		)
+		if encoding == EncodingGzip {
+			w, err = gzip.NewWriterLevel(io.Discard, level)
+		} else {
+			w, err = zlib.NewWriterLevel(io.Discard, level)
+		}
+		if err != nil {
+			panic(err)
+		}
+		return w
+	}
+	return cp
+}
+
+func (cp *compressor) get(w io.Writer) io.WriteCloser {
+	zw := cp.pool.Get().(io.WriteCloser)
+	zw.(interface{ Reset(io.Writer) }).Reset(w)
+	return zw
+}
+
+func (cp *compressor) put(zw io.WriteCloser) {
+	cp.pool.Put(zw)
+}
+
+type compressPolicy struct {
+	config      CompressConfig
+	compressors map[string]*compressor
+}
+
+func newCompressPolicy(config CompressConfig) (*compressPolicy, error) {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.Level == 0 {
+		config.Level = gzip.DefaultCompression
+	}
+	if config.Level < gzip.HuffmanOnly || config.Level > gzip.BestCompression {
+		return nil, errors.Errorf("invalid compress level:%v", config.Level)
+	}
+	if config.MinLength <= 0 {
+		config.MinLength = defaultCompressMinLength
+	}
+	if len(config.ExcludedContentTypes) == 0 {
+		config.ExcludedContentTypes = DefaultCompressExcludedContentTypes
+	}
+
+	return &compressPolicy{
+		config: config,
+		compressors: map[string]*compressor{
+			EncodingGzip:    newCompressor(EncodingGzip, config.Level),
+			EncodingDeflate: newCompressor(EncodingDeflate, config.Level),
+		},
+	}, nil
+}
+
+// negotiateEncoding returns gzip or deflate with the highest q of acceptEncoding, gzip is preferred
+// if equal, empty if neither is acceptable
+func negotiateEncoding(acceptEncoding string) string {
+	type candidate struct {
+		encoding string
+		q        float64
+	}
+	var candidates []candidate
+	wildcard := -1.0
+	qOf := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		switch name {
+		case EncodingGzip, "x-gzip":
+			qOf[EncodingGzip] = q
+		case EncodingDeflate:
+			qOf[EncodingDeflate] = q
+		case "*":
+			wildcard = q
+		}
+	}
+	for _, enc := range []string{EncodingGzip, EncodingDeflate} {
+		q, ok := qOf[enc]
+		if !ok {
+			q = wildcard
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{encoding: enc, q: q})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].encoding
+}
+
+func (p *compressPolicy) excluded(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range p.config.ExcludedContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *compressPolicy) handle(c echo.Context, next echo.HandlerFunc) error {
+	if p.config.Disable || p.config.Skipper(c) {
+		return next(c)
+	}
+
+	req := c.Request()
+	res := c.Response()
+	res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+	encoding := negotiateEncoding(req.Header.Get(echo.HeaderAcceptEncoding))
+	if encoding == "" || req.Method == http.MethodHead || req.Header.Get("Range") != "" {
+		return next(c)
+	}
+
+	rw := res.Writer
+	cw := &compressResponseWriter{ResponseWriter: rw, policy: p, compressor: p.compressors[encoding]}
+	res.Writer = cw
+
+	err := next(c)
+	if err != nil && !res.Committed {
+		// let echo.HTTPErrorHandler respond the error as usual
+		res.Writer = rw
+		return err
+	}
+	cw.close()
+	res.Writer = rw
+	return err
+}
+
+// compressResponseWriter buffers the body up to MinLength to decide whether to compress
+type compressResponseWriter struct {
+	http.ResponseWriter
+	policy     *compressPolicy
+	compressor *compressor
+
+	status  int
+	buf     []byte
+	decided bool
+	zw      io.WriteCloser
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.zw != nil {
+			return w.zw.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.policy.config.MinLength {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide writes the header and the buffered body, compressed if the response is eligible
+func (w *compressResponseWriter) decide() error {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	h := w.Header()
+	if len(w.buf) > 0 && h.Get(echo.HeaderContentType) == "" {
+		h.Set(echo.HeaderContentType, http.DetectContentType(w.buf))
+	}
+	if len(w.buf) >= w.policy.config.MinLength && w.status != http.StatusNoContent &&
+		w.status != http.StatusNotModified && w.status != http.StatusPartialContent &&
+		h.Get(echo.HeaderContentEncoding) == "" && h.Get("Content-Range") == "" &&
+		!w.policy.excluded(h.Get(echo.HeaderContentType)) {
+		h.Set(echo.HeaderContentEncoding, w.compressor.encoding)
+		h.Del(echo.HeaderContentLength)
+		w.zw = w.compressor.get(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.zw != nil {
+		_, err = w.zw.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *compressResponseWriter) close() {
+	if !w.decided {
+		if w.status == 0 {
+			return
+		}
+		_ = w.decide()
+	}
+	if w.zw != nil {
+		_ = w.zw.Close()
+		w.compressor.put(w.zw)
+		w.zw = nil
+	}
+}
+
+func (w *compressResponseWriter) Flush() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	if zw, ok := w.zw.(interface{ Flush() error }); ok {
+		_ = zw.Flush()
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Compress returns a Compress middleware with default config.
+func Compress() echo.MiddlewareFunc {
+	return CompressWithConfig(CompressConfig{})
+}
+
+// CompressWithConfig returns a Compress middleware which compresses the response body by gzip or
+// deflate negotiated by Accept-Encoding. It should be used before Logger to log the plaintext body_out.
+// It panics if config is invalid.
+func CompressWithConfig(config CompressConfig) echo.MiddlewareFunc {
+	p, err := newCompressPolicy(config)
+	if err != nil {
+		panic(err)
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			return p.handle(c, next)
+		}
+	}
+}
+
+// SetCompressConfig enables the compression of responses, it fails if cc is invalid
+func (agw *ApiGateway) SetCompressConfig(cc *CompressConfig) error {
+	if cc == nil {
+		agw.compressPolicy = nil
+		return nil
+	}
+	p, err := newCompressPolicy(*cc)
+	if err != nil {
+		return err
+	}
+	agw.compressPolicy = p
+	return nil
+}
+
+// SetGroupCompressConfig overrides the compression of the routes under prefix, it fails if cc is invalid
+func (agw *ApiGateway) SetGroupCompressConfig(prefix string, cc *CompressConfig) error {
+	p, err := newCompressPolicy(*cc)
+	if err != nil {
+		return errors.Wrapf(err, "compression of group %v", prefix)
+	}
+	if agw.groupCompress == nil {
+		agw.groupCompress = make(map[string]*compressPolicy)
+	}
+	agw.groupCompress[prefix] = p
+	return nil
+}
+
+type prefixedCompressPolicy struct {
+	prefix string
+	policy *compressPolicy
+}
+
+func (agw *ApiGateway) compressMiddleware() echo.MiddlewareFunc {
+	defPolicy := agw.compressPolicy
+	groups := make([]prefixedCompressPolicy, 0, len(agw.groupCompress))
+	for prefix, p := range agw.groupCompress {
+		groups = append(groups, prefixedCompressPolicy{prefix: prefix, policy: p})
+	}
+	sort.Slice(groups, func(i, j int) bool { return len(groups[i].prefix) > len(groups[j].prefix) })
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p := defPolicy
+			for _, g := range groups {
+				if pathHasPrefix(c.Request().URL.Path, g.prefix) {
+					p = g.policy
+					break
+				}
+			}
+
+			if p == nil {
+				return next(c)
+			}
+			return p.handle(c, next)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package httpx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	testCases := []struct {
+		acceptEncoding, encoding string
+	}{
+		{"", ""},
+		{"gzip", EncodingGzip},
+		{"deflate", EncodingDeflate},
+		{"deflate, gzip", EncodingGzip},
+		{"gzip;q=0.5, deflate", EncodingDeflate},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"br", ""},
+		{"*", EncodingGzip},
+		{"gzip;q=0, *;q=0.1", EncodingDeflate},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.encoding, negotiateEncoding(tc.acceptEncoding), tc.acceptEncoding)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	var out bytes.Buffer
+	agw := newTestApiGateway(t)
+	agw.LogConf.ContentFormatAfter = "${path} ${body_out}"
+	agw.LogConf.Timing = AccessLogAfterRun
+	agw.LogConf.BodyBufferSize = 64 * 1024
+	agw.Logger.Out = &out
+	require.NoError(t, agw.SetCompressConfig(&CompressConfig{MinLength: 200}))
+	require.NoError(t, agw.SetGroupConfigs(&GroupConfig{Prefix: "/raw", Compress: &CompressConfig{Disable: true}}))
+	agw.configEcho()
+
+	large := strings.Repeat("abcdefgh", 32)
+	agw.GET("/large", func(c echo.Context) error { return SendResp(c, SuccessResp(large)) })
+	agw.GET("/small", func(c echo.Context) error { return SendResp(c, SuccessResp("small")) })
+	agw.GET("/png", func(c echo.Context) error { return c.Blob(http.StatusOK, "image/png", []byte(large)) })
+	agw.GET("/raw/large", func(c echo.Context) error { return SendResp(c, SuccessResp(large)) })
+	agw.GET("/empty", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderAcceptEncoding, acceptEncoding)
+		return serveTestRequest(agw, req)
+	}
+
+	// gzip
+	out.Reset()
+	rec := get("/large", "gzip, deflate")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, EncodingGzip, rec.Header().Get(echo.HeaderContentEncoding))
+	require.Equal(t, echo.HeaderAcceptEncoding, rec.Header().Get(echo.HeaderVary))
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Contains(t, string(b), large)
+	// body_out is plaintext
+	require.Contains(t, out.String(), large)
+
+	// deflate
+	rec = get("/large", "deflate")
+	require.Equal(t, EncodingDeflate, rec.Header().Get(echo.HeaderContentEncoding))
+	zlr, err := zlib.NewReader(rec.Body)
+	require.NoError(t, err)
+	b, err = io.ReadAll(zlr)
+	require.NoError(t, err)
+	require.Contains(t, string(b), large)
+
+	// not acceptable
+	rec = get("/large", "")
+	require.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	require.Contains(t, rec.Body.String(), large)
+
+	// small body
+	rec = get("/small", "gzip")
+	require.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	require.Contains(t, rec.Body.String(), `"Result":"small"`)
+
+	// compressed content type
+	rec = get("/png", "gzip")
+	require.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	require.Equal(t, large, rec.Body.String())
+
+	// disabled by group
+	rec = get("/raw/large", "gzip")
+	require.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	require.Contains(t, rec.Body.String(), large)
+
+	// no content
+	rec = get("/empty", "gzip")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+
+	// errors are responded by echo
+	rec = get("/not_found", "gzip")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+}
+
+func TestCompressLevel(t *testing.T) {
+	for _, level := range []int{gzip.HuffmanOnly, gzip.DefaultCompression, 0, gzip.BestSpeed, gzip.BestCompression} {
+		_, err := newCompressPolicy(CompressConfig{Level: level})
+		require.NoError(t, err, level)
+	}
+	for _, level := range []int{-3, 10} {
+		_, err := newCompressPolicy(CompressConfig{Level: level})
+		require.Error(t, err, level)
+	}
+
+	require.Panics(t, func() { CompressWithConfig(CompressConfig{Level: 10}) })
+	agw := newTestApiGateway(t)
+	require.Error(t, agw.SetCompressConfig(&CompressConfig{Level: 10}))
+	err := agw.SetGroupConfigs(&GroupConfig{Prefix: "/raw", Compress: &CompressConfig{Level: -3}})
+	require.ErrorContains(t, err, "compression of group /raw")
+}
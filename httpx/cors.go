@@ -0,0 +1,253 @@
+package httpx
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/log"
+)
+
+// CORSConfig defines the Cross-Origin Resource Sharing policy of ApiGateway.
+type CORSConfig struct {
+	// Disable turns CORS off, e.g. for internal-only gateways
+	Disable bool
+	// AllowOrigins supports
+	//
+	// - "*", ignored if AllowCredentials is true
+	// - exact origin, e.g. "https://www.example.com"
+	// - wildcard subdomains, e.g. "https://*.example.com", or "*.example.com" for any scheme
+	AllowOrigins []string
+	// AllowMethods is DefaultCORSConfig.AllowMethods if empty
+	AllowMethods []string
+	// AllowHeaders "*" reflects Access-Control-Request-Headers of the preflight request
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	// MaxAge is how long in seconds the preflight result could be cached, 0 means not to send
+	MaxAge int `vx_default:"600"`
+}
+
+var (
+	// DefaultCORSConfig is used by ApiGateway if SetCORSConfig is never called
+	DefaultCORSConfig = CORSConfig{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch,
+			http.MethodPost, http.MethodDelete},
+		AllowHeaders:  []string{"*"},
+		ExposeHeaders: []string{"*"},
+	}
+)
+
+type corsPolicy struct {
+	allowAll         bool
+	allowOrigins     []string
+	allowMethods     string
+	allowHeaders     string
+	reflectHeaders   bool
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+func newCORSPolicy(config CORSConfig) *corsPolicy {
+	if config.Disable {
+		return nil
+	}
+
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = DefaultCORSConfig.AllowMethods
+	}
+
+	p := &corsPolicy{
+		allowMethods:     strings.Join(config.AllowMethods, ","),
+		exposeHeaders:    strings.Join(config.ExposeHeaders, ","),
+		allowCredentials: config.AllowCredentials,
+	}
+
+	for _, o := range config.AllowOrigins {
+		if o == "*" {
+			if config.AllowCredentials {
+				log.Warn("CORS AllowOrigins \"*\" is ignored since AllowCredentials is true")
+				continue
+			}
+			p.allowAll = true
+			continue
+		}
+		p.allowOrigins = append(p.allowOrigins, strings.ToLower(o))
+	}
+
+	for _, h := range config.AllowHeaders {
+		if h == "*" {
+			p.reflectHeaders = true
+			break
+		}
+	}
+	if !p.reflectHeaders {
+		p.allowHeaders = strings.Join(config.AllowHeaders, ",")
+	}
+
+	if config.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(config.MaxAge)
+	}
+
+	return p
+}
+
+// matchOriginPattern matches origin with exact origin or wildcard subdomain pattern
+func matchOriginPattern(origin, pattern string) bool {
+	i := strings.Index(pattern, "*")
+	if i < 0 {
+		return origin == pattern
+	}
+
+	if !strings.Contains(pattern, "://") {
+		if j := strings.Index(origin, "://"); j >= 0 {
+			origin = origin[j+3:]
+		}
+	}
+
+	prefix, suffix := pattern[:i], pattern[i+1:]
+	if len(origin) <= len(prefix)+len(suffix) ||
+		!strings.HasPrefix(origin, prefix) ||
+		!strings.HasSuffix(origin, suffix) {
+		return false
+	}
+
+	return !strings.ContainsAny(origin[len(prefix):len(origin)-len(suffix)], "/:")
+}
+
+// allowOrigin returns the value of Access-Control-Allow-Origin, "" if not allowed
+func (p *corsPolicy) allowOrigin(origin string) string {
+	lower := strings.ToLower(origin)
+	for _, o := range p.allowOrigins {
+		if matchOriginPattern(lower, o) {
+			return origin
+		}
+	}
+
+	if p.allowAll {
+		return "*"
+	}
+
+	return ""
+}
+
+func (p *corsPolicy) handle(c echo.Context, next echo.HandlerFunc) error {
+	req := c.Request()
+	res := c.Response()
+	origin := req.Header.Get(echo.HeaderOrigin)
+	preflight := req.Method == http.MethodOptions &&
+		req.Header.Get(echo.HeaderAccessControlRequestMethod) != ""
+
+	res.Header().Add(echo.HeaderVary, echo.HeaderOrigin)
+	if origin == "" {
+		return next(c)
+	}
+
+	allowOrigin := p.allowOrigin(origin)
+
+	// Simple request
+	if !preflight {
+		if allowOrigin == "" {
+			return next(c)
+		}
+		res.Header().Set(echo.HeaderAccessControlAllowOrigin, allowOrigin)
+		if p.allowCredentials {
+			res.Header().Set(echo.HeaderAccessControlAllowCredentials, "true")
+		}
+		if p.exposeHeaders != "" {
+			res.Header().Set(echo.HeaderAccessControlExposeHeaders, p.exposeHeaders)
+		}
+		return next(c)
+	}
+
+	// Preflight request
+	res.Header().Add(echo.HeaderVary, echo.HeaderAccessControlRequestMethod)
+	res.Header().Add(echo.HeaderVary, echo.HeaderAccessControlRequestHeaders)
+	if allowOrigin == "" {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	res.Header().Set(echo.HeaderAccessControlAllowOrigin, allowOrigin)
+	res.Header().Set(echo.HeaderAccessControlAllowMethods, p.allowMethods)
+	if p.allowCredentials {
+		res.Header().Set(echo.HeaderAccessControlAllowCredentials, "true")
+	}
+
+	allowHeaders := p.allowHeaders
+	if p.reflectHeaders {
+		allowHeaders = req.Header.Get(echo.HeaderAccessControlRequestHeaders)
+	}
+	if allowHeaders != "" {
+		res.Header().Set(echo.HeaderAccessControlAllowHeaders, allowHeaders)
+	}
+
+	if p.maxAge != "" {
+		res.Header().Set(echo.HeaderAccessControlMaxAge, p.maxAge)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// CORSWithConfig returns a CORS middleware, which does nothing if config.Disable
+func CORSWithConfig(config CORSConfig) echo.MiddlewareFunc {
+	p := newCORSPolicy(config)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if p == nil {
+				return next(c)
+			}
+			return p.handle(c, next)
+		}
+	}
+}
+
+// pathHasPrefix reports whether urlPath is prefix itself or under prefix
+func pathHasPrefix(urlPath, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	return urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/")
+}
+
+type prefixedCORSPolicy struct {
+	prefix string
+	policy *corsPolicy
+}
+
+// corsMiddleware applies the policy of the longest matched group prefix, or the gateway policy.
+// It works on the raw path so that preflight requests without OPTIONS routes are handled as well.
+func (agw *ApiGateway) corsMiddleware() echo.MiddlewareFunc {
+	conf := DefaultCORSConfig
+	if agw.corsConf != nil {
+		conf = *agw.corsConf
+	}
+	defPolicy := newCORSPolicy(conf)
+
+	groups := make([]prefixedCORSPolicy, 0, len(agw.groupCORSConf))
+	for prefix, gc := range agw.groupCORSConf {
+		groups = append(groups, prefixedCORSPolicy{prefix: prefix, policy: newCORSPolicy(*gc)})
+	}
+	sort.Slice(groups, func(i, j int) bool { return len(groups[i].prefix) > len(groups[j].prefix) })
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p := defPolicy
+			for _, g := range groups {
+				if pathHasPrefix(c.Request().URL.Path, g.prefix) {
+					p = g.policy
+					break
+				}
+			}
+
+			if p == nil {
+				return next(c)
+			}
+			return p.handle(c, next)
+		}
+	}
+}
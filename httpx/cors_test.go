@@ -0,0 +1,92 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/log"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestApiGateway(t *testing.T) *ApiGateway {
+	agw, err := NewApiGateway(context.Background(), "127.0.0.1", "0", "test", &LogConfig{
+		LogFile: log.FileConfig{Filename: "discard"},
+		Level:   "info",
+	}, nil)
+	require.NoError(t, err)
+	return agw
+}
+
+func serveTestRequest(agw *ApiGateway, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	agw.Echo.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMatchOriginPattern(t *testing.T) {
+	testCases := []struct {
+		origin, pattern string
+		match           bool
+	}{
+		{"https://www.example.com", "https://www.example.com", true},
+		{"https://a.example.com", "https://*.example.com", true},
+		{"https://a.b.example.com", "https://*.example.com", true},
+		{"http://a.example.com", "https://*.example.com", false},
+		{"https://example.com", "https://*.example.com", false},
+		{"https://evil.com/.example.com", "https://*.example.com", false},
+		{"https://a.example.com.evil.com", "https://*.example.com", false},
+		{"http://a.example.com", "*.example.com", true},
+	}
+
+	for _, tc := range testCases {
+		require.Equal(t, tc.match, matchOriginPattern(tc.origin, tc.pattern), "%v %v", tc.origin, tc.pattern)
+	}
+}
+
+func TestApiGatewayCORS(t *testing.T) {
+	agw := newTestApiGateway(t)
+	agw.SetCORSConfig(&CORSConfig{
+		AllowOrigins:     []string{"*", "https://*.example.com"},
+		AllowHeaders:     []string{"*"},
+		AllowCredentials: true,
+		MaxAge:           60,
+	})
+	agw.SetGroupCORSConfig("/internal", &CORSConfig{Disable: true})
+	agw.configEcho()
+	agw.GET("/api/v1", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	agw.GET("/internal/v1", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	// simple request from allowed origin
+	req := httptest.NewRequest(http.MethodGet, "/api/v1", nil)
+	req.Header.Set(echo.HeaderOrigin, "https://app.example.com")
+	rec := serveTestRequest(agw, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "https://app.example.com", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	require.Equal(t, "true", rec.Header().Get(echo.HeaderAccessControlAllowCredentials))
+
+	// "*" is ignored with credentials
+	req = httptest.NewRequest(http.MethodGet, "/api/v1", nil)
+	req.Header.Set(echo.HeaderOrigin, "https://evil.com")
+	rec = serveTestRequest(agw, req)
+	require.Equal(t, "", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+
+	// preflight
+	req = httptest.NewRequest(http.MethodOptions, "/api/v1", nil)
+	req.Header.Set(echo.HeaderOrigin, "https://app.example.com")
+	req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPost)
+	req.Header.Set(echo.HeaderAccessControlRequestHeaders, "X-Custom")
+	rec = serveTestRequest(agw, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "X-Custom", rec.Header().Get(echo.HeaderAccessControlAllowHeaders))
+	require.Equal(t, "60", rec.Header().Get(echo.HeaderAccessControlMaxAge))
+
+	// disabled for group
+	req = httptest.NewRequest(http.MethodGet, "/internal/v1", nil)
+	req.Header.Set(echo.HeaderOrigin, "https://app.example.com")
+	rec = serveTestRequest(agw, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+}
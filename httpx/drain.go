@@ -0,0 +1,229 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/errors"
+)
+
+const (
+	drainNotifyContextKey = "httpx.drain_notify"
+
+	defaultDrainTimeout    = 3 * time.Second
+	defaultShutdownTimeout = time.Second
+)
+
+// ShutdownConfig defines how ApiGateway.Stop drains the in-flight requests. Note that
+// graceful.Graceful.SetQuitTimeout should be longer than DrainTimeout plus ShutdownTimeout,
+// the defaults fit in its default 5s.
+type ShutdownConfig struct {
+	// DrainTimeout is how long the in-flight requests could finish after new connections
+	// and requests are refused
+	DrainTimeout time.Duration `vx_default:"3s"`
+	// ShutdownTimeout is how long to wait for the handlers to return after the remaining
+	// connections are closed forcibly
+	ShutdownTimeout time.Duration `vx_default:"1s"`
+}
+
+// InFlightRequest is a request not finished when ApiGateway is stopping
+type InFlightRequest struct {
+	RequestId string
+	Method    string
+	Uri       string
+	RemoteIp  string
+	Elapsed   time.Duration
+}
+
+// ShutdownReport is the result of ApiGateway.Shutdown
+type ShutdownReport struct {
+	// Drained is true if all the in-flight requests finished within DrainTimeout
+	Drained bool
+	Elapsed time.Duration
+	// InFlight are the requests still running when DrainTimeout hits, longest first
+	InFlight []InFlightRequest
+}
+
+type inFlightEntry struct {
+	c     echo.Context
+	start time.Time
+}
+
+// drainer tracks the in-flight requests and notifies the long-lived handlers to finish
+type drainer struct {
+	mu       sync.Mutex
+	seq      uint64
+	inFlight map[uint64]*inFlightEntry
+	wg       sync.WaitGroup
+	// draining is set under mu, so that no request is added to wg once wait starts
+	draining bool
+
+	notifyCh chan struct{}
+	once     sync.Once
+	hooks    []func()
+}
+
+func newDrainer() *drainer {
+	return &drainer{
+		inFlight: make(map[uint64]*inFlightEntry),
+		notifyCh: make(chan struct{}),
+	}
+}
+
+func (d *drainer) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			d.mu.Lock()
+			if d.draining {
+				d.mu.Unlock()
+				c.Response().Header().Set("Connection", "close")
+				return SendResp(c, newErrResp(http.StatusServiceUnavailable, ""))
+			}
+			d.seq++
+			id := d.seq
+			d.inFlight[id] = &inFlightEntry{c: c, start: time.Now()}
+			d.wg.Add(1)
+			d.mu.Unlock()
+
+			defer func() {
+				d.mu.Lock()
+				delete(d.inFlight, id)
+				d.mu.Unlock()
+				d.wg.Done()
+			}()
+
+			c.Set(drainNotifyContextKey, d.notifyCh)
+			return next(c)
+		}
+	}
+}
+
+func (d *drainer) onDrain(f func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks = append(d.hooks, f)
+}
+
+// start refuses new requests and notifies the hooks and DrainNotify once
+func (d *drainer) start() {
+	d.once.Do(func() {
+		d.mu.Lock()
+		d.draining = true
+		hooks := d.hooks
+		d.mu.Unlock()
+
+		close(d.notifyCh)
+		for _, f := range hooks {
+			go f()
+		}
+	})
+}
+
+func (d *drainer) snapshot() []InFlightRequest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	reqs := make([]InFlightRequest, 0, len(d.inFlight))
+	for _, e := range d.inFlight {
+		req := e.c.Request()
+		reqs = append(reqs, InFlightRequest{
+			RequestId: GetRequestId(e.c),
+			Method:    req.Method,
+			Uri:       req.RequestURI,
+			RemoteIp:  GetRealIp(req),
+			Elapsed:   now.Sub(e.start),
+		})
+	}
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].Elapsed > reqs[j].Elapsed })
+	return reqs
+}
+
+// wait returns false if the in-flight handlers are still running after timeout
+func (d *drainer) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// DrainNotify returns a channel closed once ApiGateway starts draining, long-lived handlers,
+// e.g. streaming, should finish in time when it is closed
+func DrainNotify(c echo.Context) <-chan struct{} {
+	ch, _ := c.Get(drainNotifyContextKey).(chan struct{})
+	return ch
+}
+
+// SetShutdownConfig overrides the timeouts of Stop
+func (agw *ApiGateway) SetShutdownConfig(sc *ShutdownConfig) {
+	agw.shutdownConf = sc
+}
+
+// OnDrain registers f to be called in a new goroutine once Stop starts draining
+func (agw *ApiGateway) OnDrain(f func()) {
+	agw.drainer.onDrain(f)
+}
+
+// Shutdown fails readiness, refuses new connections and requests, notifies the long-lived
+// handlers by OnDrain and DrainNotify, then waits DrainTimeout for the in-flight requests.
+// The remaining connections are closed forcibly and reported.
+func (agw *ApiGateway) Shutdown(ctx context.Context) (*ShutdownReport, error) {
+	conf := ShutdownConfig{}
+	if agw.shutdownConf != nil {
+		conf = *agw.shutdownConf
+	}
+	if conf.DrainTimeout <= 0 {
+		conf.DrainTimeout = defaultDrainTimeout
+	}
+	if conf.ShutdownTimeout <= 0 {
+		conf.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	start := time.Now()
+	agw.healthReg.SetShuttingDown()
+	agw.drainer.start()
+
+	drainCtx, cancel := context.WithTimeout(ctx, conf.DrainTimeout)
+	defer cancel()
+
+	// Shutdown closes the listeners and idle connections, then waits for the active ones
+	err := agw.Echo.Shutdown(drainCtx)
+	if err == nil {
+		// hijacked connections, e.g. websocket, are not tracked by http.Server
+		err = drainCtx.Err()
+		if agw.drainer.wait(max(time.Until(start.Add(conf.DrainTimeout)), 0)) {
+			return &ShutdownReport{Drained: true, Elapsed: time.Since(start)}, nil
+		}
+	}
+
+	report := &ShutdownReport{InFlight: agw.drainer.snapshot()}
+	for _, r := range report.InFlight {
+		agw.Logger.Warnf("Request still in flight after %v, id:%v, %v %v from %v, elapsed:%v",
+			conf.DrainTimeout, r.RequestId, r.Method, r.Uri, r.RemoteIp, r.Elapsed)
+	}
+
+	if cerr := agw.Echo.Close(); cerr != nil {
+		agw.Logger.Warnf("Failed to close connections, err:%v", cerr)
+	}
+	if !agw.drainer.wait(conf.ShutdownTimeout) {
+		agw.Logger.Warnf("Handlers still running after %v", conf.ShutdownTimeout)
+	}
+	report.Elapsed = time.Since(start)
+
+	if err == nil {
+		err = context.DeadlineExceeded
+	}
+	return report, errors.Wrapf(err, "%d requests in flight", len(report.InFlight))
+}
@@ -0,0 +1,126 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+func startTestServer(t *testing.T, agw *ApiGateway) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	agw.Echo.Listener = l
+	agw.Echo.HideBanner, agw.Echo.HidePort = true, true
+	go func() { _ = agw.Echo.StartServer(agw.Echo.Server) }()
+	return "http://" + l.Addr().String()
+}
+
+func TestShutdownDrain(t *testing.T) {
+	agw := newTestApiGateway(t)
+	agw.SetShutdownConfig(&ShutdownConfig{DrainTimeout: 300 * time.Millisecond, ShutdownTimeout: 100 * time.Millisecond})
+	agw.configEcho()
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	agw.GET("/slow", func(c echo.Context) error {
+		started <- struct{}{}
+		<-release
+		return SendResp(c, SuccessResp(nil))
+	})
+	agw.GET("/stream", func(c echo.Context) error {
+		started <- struct{}{}
+		<-DrainNotify(c)
+		return SendResp(c, SuccessResp(nil))
+	})
+	var hooked atomic.Bool
+	agw.OnDrain(func() { hooked.Store(true) })
+
+	url := startTestServer(t, agw)
+	for _, path := range []string{"/slow", "/stream"} {
+		go func(path string) {
+			if resp, err := http.Get(url + path); err == nil {
+				_ = resp.Body.Close()
+			}
+		}(path)
+	}
+	<-started
+	<-started
+
+	report, err := agw.Shutdown(context.Background())
+	close(release)
+	require.Error(t, err)
+	require.False(t, report.Drained)
+	require.Len(t, report.InFlight, 1)
+	require.Equal(t, http.MethodGet, report.InFlight[0].Method)
+	require.Equal(t, "/slow", report.InFlight[0].Uri)
+	require.NotEmpty(t, report.InFlight[0].RequestId)
+	require.True(t, hooked.Load())
+
+	// new connections are refused
+	_, err = http.Get(url + "/slow")
+	require.Error(t, err)
+
+	// new requests on existing connections are refused
+	rec := serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "close", rec.Header().Get("Connection"))
+}
+
+func TestShutdownDrained(t *testing.T) {
+	agw := newTestApiGateway(t)
+	agw.configEcho()
+
+	started := make(chan struct{})
+	agw.GET("/stream", func(c echo.Context) error {
+		close(started)
+		<-DrainNotify(c)
+		return SendResp(c, SuccessResp(nil))
+	})
+
+	url := startTestServer(t, agw)
+	done := make(chan int)
+	go func() {
+		resp, err := http.Get(url + "/stream")
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	<-started
+
+	report, err := agw.Shutdown(context.Background())
+	require.NoError(t, err)
+	require.True(t, report.Drained)
+	require.Empty(t, report.InFlight)
+	require.Equal(t, http.StatusOK, <-done)
+}
+
+func TestDrainerNoEscape(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		d := newDrainer()
+		var running atomic.Int32
+		h := d.middleware()(func(c echo.Context) error {
+			running.Add(1)
+			defer running.Add(-1)
+			time.Sleep(time.Millisecond)
+			return nil
+		})
+
+		e := echo.New()
+		for j := 0; j < 8; j++ {
+			go func() {
+				c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+				_ = h(c)
+			}()
+		}
+		d.start()
+		require.True(t, d.wait(time.Second))
+		require.Zero(t, running.Load())
+	}
+}
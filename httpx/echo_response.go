@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/log"
 )
 
 func NewEtag(modTime time.Time, length int64) string {
@@ -62,37 +63,57 @@ func CheckIfModifiedSince(r *http.Request, modtime time.Time) bool {
 	return false
 }
 
+// SendResp responds resp by WriteResp unless the response is committed
 func SendResp(c echo.Context, resp error) (err error) {
 	if c.Response().Committed {
 		return resp
 	}
 
+	_, pretty := c.QueryParams()["pretty"]
+	return writeResp(c.Response(), c.Request(), resp, c.Echo().Debug || pretty)
+}
+
+// WriteResp responds resp as the JsonResponse envelope, 200 without body if resp is nil. It works
+// with plain http.Handler, the request id and the dictionary are taken from req.Context if any.
+func WriteResp(w http.ResponseWriter, req *http.Request, resp error) error {
+	_, pretty := req.URL.Query()["pretty"]
+	return writeResp(w, req, resp, pretty)
+}
+
+func writeResp(w http.ResponseWriter, req *http.Request, resp error, pretty bool) error {
 	if resp == nil {
-		rid := errCodeDic.NewRequestId()
-		c.Response().Header().Set(echo.HeaderXRequestID, rid)
-		return c.NoContent(http.StatusOK)
+		w.Header().Set(echo.HeaderXRequestID, requestIdOf(req))
+		w.WriteHeader(http.StatusOK)
+		return nil
 	}
 
 	jr := Wrap(resp)
+	jr.resolve(ErrCodeDictionaryFromContext(req.Context()))
 	if jr.RequestId == "" {
-		jr.RequestId = errCodeDic.NewRequestId()
+		jr.RequestId = requestIdOf(req)
 	}
-	c.Response().Header().Set(echo.HeaderXRequestID, jr.RequestId)
+	w.Header().Set(echo.HeaderXRequestID, jr.RequestId)
 
-	return jr.cjson(c)
+	return jr.writeJSON(w, pretty)
+}
+
+// requestIdOf returns the id stored by RequestId middleware in req.Context, or a new one
+func requestIdOf(req *http.Request) string {
+	if rid := log.RequestIdFromContext(req.Context()); rid != "" {
+		return rid
+	}
+	return ErrCodeDictionaryFromContext(req.Context()).NewRequestId()
 }
 
 func ServeContent(w http.ResponseWriter, req *http.Request, name string, modTime time.Time, length int64, content io.ReadSeeker) {
-	rid := errCodeDic.NewRequestId()
-	w.Header().Set(echo.HeaderXRequestID, rid)
+	w.Header().Set(echo.HeaderXRequestID, requestIdOf(req))
 	w.Header().Set("Etag", NewEtag(modTime, length))
 
 	http.ServeContent(w, req, name, modTime, content)
 }
 
 func ServeContentWithTag(w http.ResponseWriter, req *http.Request, name string, modTime time.Time, localEtag string, content io.ReadSeeker) {
-	rid := errCodeDic.NewRequestId()
-	w.Header().Set(echo.HeaderXRequestID, rid)
+	w.Header().Set(echo.HeaderXRequestID, requestIdOf(req))
 	w.Header().Set("Etag", localEtag)
 	http.ServeContent(w, req, name, modTime, content)
 }
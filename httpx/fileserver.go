@@ -0,0 +1,264 @@
+package httpx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/errors"
+)
+
+const (
+	ETagWeak   = "weak"
+	ETagStrong = "strong"
+	ETagNone   = "none"
+)
+
+// FileServerConfig defines the config for FileServer.
+type FileServerConfig struct {
+	// Root is the directory served if FS is nil
+	Root string
+	// FS is the file system served, e.g. embed.FS
+	FS fs.FS `json:"-"`
+
+	// Index is served for the directories, no index if empty
+	Index string `vx_default:"index.html"`
+	// Browse lists the directories without Index
+	Browse bool
+
+	// ETag is weak, from the modification time and size, strong, from the SHA-256 of the
+	// content, or none
+	ETag string `vx_default:"weak"`
+
+	// Download responds Content-Disposition: attachment for all the files, otherwise only for
+	// the requests with query parameter DownloadParam, e.g. ?download=1
+	Download      bool
+	DownloadParam string `vx_default:"download"`
+
+	// CacheControl is the Cache-Control of the files by extension, e.g.
+	// {".html": "no-cache", ".js": "public, max-age=31536000"}, DefaultCacheControl if absent
+	CacheControl        map[string]string
+	DefaultCacheControl string
+}
+
+type fileServer struct {
+	config FileServerConfig
+	fsys   fs.FS
+	// strongETags caches the SHA-256 of files by name, invalidated by modification time and size
+	strongETags sync.Map
+}
+
+type strongETag struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+func newFileServer(config FileServerConfig) *fileServer {
+	if config.ETag == "" {
+		config.ETag = ETagWeak
+	}
+	if config.DownloadParam == "" {
+		config.DownloadParam = "download"
+	}
+
+	fsys := config.FS
+	if fsys == nil {
+		if config.Root == "" {
+			panic(errors.New("neither FS nor Root of FileServerConfig is set"))
+		}
+		fsys = os.DirFS(config.Root)
+	}
+	return &fileServer{config: config, fsys: fsys}
+}
+
+// cleanFilePath returns the name in fsys of the request path p, false if p escapes the root
+func cleanFilePath(p string) (string, bool) {
+	if strings.Contains(p, "\\") || strings.Contains(p, "\x00") {
+		return "", false
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." {
+			return "", false
+		}
+	}
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+func (s *fileServer) handle(c echo.Context) error {
+	// the router matches URL.RawPath if set, otherwise the decoded URL.Path
+	p := c.Param("*")
+	if c.Request().URL.RawPath != "" {
+		var err error
+		if p, err = url.PathUnescape(p); err != nil {
+			return SendResp(c, newErrResp(http.StatusBadRequest, "").WithMsgf("invalid path"))
+		}
+	}
+	name, ok := cleanFilePath(p)
+	if !ok {
+		return SendResp(c, newErrResp(http.StatusNotFound, ""))
+	}
+
+	fi, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return s.sendOpenError(c, err)
+	}
+
+	if fi.IsDir() {
+		if reqPath := c.Request().URL.Path; !strings.HasSuffix(reqPath, "/") {
+			return c.Redirect(http.StatusMovedPermanently, reqPath+"/"+queryOf(c.Request()))
+		}
+		if s.config.Index != "" {
+			indexName := path.Join(name, s.config.Index)
+			if ifi, err := fs.Stat(s.fsys, indexName); err == nil && !ifi.IsDir() {
+				return s.serveFile(c, indexName, ifi)
+			}
+		}
+		if s.config.Browse {
+			return s.listDir(c, name)
+		}
+		return SendResp(c, newErrResp(http.StatusNotFound, ""))
+	}
+	return s.serveFile(c, name, fi)
+}
+
+func queryOf(req *http.Request) string {
+	if req.URL.RawQuery == "" {
+		return ""
+	}
+	return "?" + req.URL.RawQuery
+}
+
+func (s *fileServer) sendOpenError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return SendResp(c, newErrResp(http.StatusNotFound, ""))
+	case errors.Is(err, fs.ErrPermission):
+		return SendResp(c, newErrResp(http.StatusForbidden, ""))
+	default:
+		return SendResp(c, InternalErrorResp(err))
+	}
+}
+
+func (s *fileServer) serveFile(c echo.Context, name string, fi fs.FileInfo) error {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return s.sendOpenError(c, err)
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return SendResp(c, InternalErrorResp(err))
+		}
+		content = bytes.NewReader(b)
+	}
+
+	req := c.Request()
+	res := c.Response()
+	baseName := path.Base(name)
+	if cc, ok := s.config.CacheControl[strings.ToLower(path.Ext(name))]; ok {
+		res.Header().Set("Cache-Control", cc)
+	} else if s.config.DefaultCacheControl != "" {
+		res.Header().Set("Cache-Control", s.config.DefaultCacheControl)
+	}
+	if s.config.Download || req.URL.Query().Has(s.config.DownloadParam) {
+		res.Header().Set(echo.HeaderContentDisposition,
+			mime.FormatMediaType("attachment", map[string]string{"filename": baseName}))
+	}
+
+	etag, err := s.etagOf(name, fi, content)
+	if err != nil {
+		return SendResp(c, InternalErrorResp(err))
+	}
+	if etag == "" {
+		res.Header().Set(echo.HeaderXRequestID, requestIdOf(req))
+		http.ServeContent(res, req, baseName, fi.ModTime(), content)
+		return nil
+	}
+	ServeContentWithTag(res, req, baseName, fi.ModTime(), etag, content)
+	return nil
+}
+
+func (s *fileServer) etagOf(name string, fi fs.FileInfo, content io.ReadSeeker) (string, error) {
+	switch s.config.ETag {
+	case ETagNone:
+		return "", nil
+	case ETagStrong:
+		if v, ok := s.strongETags.Load(name); ok {
+			if se := v.(*strongETag); se.modTime.Equal(fi.ModTime()) && se.size == fi.Size() {
+				return se.etag, nil
+			}
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, content); err != nil {
+			return "", errors.Wrap(err)
+		}
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			return "", errors.Wrap(err)
+		}
+		etag := "\"" + hex.EncodeToString(h.Sum(nil)[:16]) + "\""
+		s.strongETags.Store(name, &strongETag{modTime: fi.ModTime(), size: fi.Size(), etag: etag})
+		return etag, nil
+	default:
+		return "W/" + NewEtag(fi.ModTime(), fi.Size()), nil
+	}
+}
+
+func (s *fileServer) listDir(c echo.Context, name string) error {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		return s.sendOpenError(c, err)
+	}
+
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() {
+			n += "/"
+		}
+		u := url.URL{Path: n}
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", u.String(), html.EscapeString(n))
+	}
+	b.WriteString("</pre>\n")
+	return c.HTML(http.StatusOK, b.String())
+}
+
+// FileServer returns a handler serving the files of config.FS or config.Root by the path parameter
+// "*", with Range, multi-range and conditional requests supported by http.ServeContent. Requests
+// escaping the root are responded 404, but symbolic links under Root are followed.
+func FileServer(config FileServerConfig) echo.HandlerFunc {
+	return newFileServer(config).handle
+}
+
+// ServeFiles registers FileServer on GET and HEAD of prefix of r, i.e. ApiGateway or RouteGroup, e.g.
+//
+//	httpx.ServeFiles(agw, "/static", httpx.FileServerConfig{FS: assets, Index: "index.html"})
+func ServeFiles(r Router, prefix string, config FileServerConfig, m ...echo.MiddlewareFunc) []*echo.Route {
+	h := FileServer(config)
+	p := strings.TrimSuffix(prefix, "/") + "/*"
+	return []*echo.Route{
+		r.Add(http.MethodGet, p, h, m...),
+		r.Add(http.MethodHead, p, h, m...),
+	}
+}
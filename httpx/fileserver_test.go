@@ -0,0 +1,145 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanFilePath(t *testing.T) {
+	testCases := []struct {
+		p, name string
+		ok      bool
+	}{
+		{"", ".", true},
+		{"a.txt", "a.txt", true},
+		{"docs/", "docs", true},
+		{"docs//a.txt", "docs/a.txt", true},
+		{"docs/./a.txt", "docs/a.txt", true},
+		{"../secret", "", false},
+		{"docs/../../secret", "", false},
+		{"docs\\..\\secret", "", false},
+		{"a\x00.txt", "", false},
+	}
+	for _, tc := range testCases {
+		name, ok := cleanFilePath(tc.p)
+		require.Equal(t, tc.ok, ok, tc.p)
+		if ok {
+			require.Equal(t, tc.name, name, tc.p)
+		}
+	}
+}
+
+func TestFileServer(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"a.txt":           {Data: []byte("0123456789"), ModTime: modTime},
+		"app.js":          {Data: []byte("console.log(1)"), ModTime: modTime},
+		"docs/index.html": {Data: []byte("<p>docs</p>"), ModTime: modTime},
+		"files/报告.txt":    {Data: []byte("report"), ModTime: modTime},
+		"100%.txt":        {Data: []byte("percent"), ModTime: modTime},
+	}
+
+	agw := newTestApiGateway(t)
+	agw.configEcho()
+	ServeFiles(agw, "/static", FileServerConfig{
+		FS:           fsys,
+		Index:        "index.html",
+		CacheControl: map[string]string{".js": "public, max-age=31536000"},
+	})
+	ServeFiles(agw.RouteGroup("/browse"), "/", FileServerConfig{FS: fsys, Browse: true, ETag: ETagStrong})
+
+	get := func(path string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		return serveTestRequest(agw, req)
+	}
+
+	rec := get("/static/a.txt")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "0123456789", rec.Body.String())
+	etag := rec.Header().Get("Etag")
+	require.Equal(t, "W/"+NewEtag(modTime, 10), etag)
+	require.NotEmpty(t, rec.Header().Get(echo.HeaderXRequestID))
+	require.Empty(t, rec.Header().Get("Cache-Control"))
+
+	// conditional requests
+	rec = get("/static/a.txt", "If-None-Match", etag)
+	require.Equal(t, http.StatusNotModified, rec.Code)
+	rec = get("/static/a.txt", "If-Modified-Since", modTime.Format(http.TimeFormat))
+	require.Equal(t, http.StatusNotModified, rec.Code)
+
+	// range and multi-range
+	rec = get("/static/a.txt", "Range", "bytes=2-5")
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+	require.Equal(t, "2345", rec.Body.String())
+	require.Equal(t, "bytes 2-5/10", rec.Header().Get("Content-Range"))
+	rec = get("/static/a.txt", "Range", "bytes=0-1,8-9")
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+	require.True(t, strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), "multipart/byteranges"))
+	require.Contains(t, rec.Body.String(), "Content-Range: bytes 8-9/10")
+	rec = get("/static/a.txt", "Range", "bytes=20-30")
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, rec.Code)
+
+	// weak etag never matches If-Range
+	rec = get("/static/a.txt", "Range", "bytes=2-5", "If-Range", etag)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// cache control by extension
+	rec = get("/static/app.js")
+	require.Equal(t, "public, max-age=31536000", rec.Header().Get("Cache-Control"))
+
+	// download
+	rec = get("/static/files/%E6%8A%A5%E5%91%8A.txt?download=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "attachment; filename*=utf-8''%E6%8A%A5%E5%91%8A.txt", rec.Header().Get(echo.HeaderContentDisposition))
+	rec = get("/static/a.txt")
+	require.Empty(t, rec.Header().Get(echo.HeaderContentDisposition))
+
+	// escaped names are decoded once
+	rec = get("/static/100%25.txt")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "percent", rec.Body.String())
+	rec = get("/static/docs%2Findex.html")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "<p>docs</p>", rec.Body.String())
+
+	// directories
+	rec = get("/static/docs?a=1")
+	require.Equal(t, http.StatusMovedPermanently, rec.Code)
+	require.Equal(t, "/static/docs/?a=1", rec.Header().Get(echo.HeaderLocation))
+	rec = get("/static/docs/")
+	require.Equal(t, "<p>docs</p>", rec.Body.String())
+	rec = get("/static/files/")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = get("/browse/files/")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `<a href="%E6%8A%A5%E5%91%8A.txt">报告.txt</a>`)
+
+	// traversal and not found
+	rec = get("/static/..%2fcors.go")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = get("/static/missing.txt")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	// strong etag
+	rec = get("/browse/a.txt")
+	etag = rec.Header().Get("Etag")
+	require.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+	rec = get("/browse/a.txt", "Range", "bytes=2-5", "If-Range", etag)
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+
+	// head
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodHead, "/static/a.txt", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "10", rec.Header().Get(echo.HeaderContentLength))
+	require.Empty(t, rec.Body.String())
+}
@@ -0,0 +1,163 @@
+package httpx
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/errors"
+)
+
+// GroupConfig is the settings of the routes under Prefix. It could be declared in code or
+// loaded by viperx, e.g.
+//
+//	[[Http.Groups]]
+//	Prefix = "/admin"
+//	DisableBodyLog = true
+//	BodyLimit = "1M"
+//	[Http.Groups.RateLimit]
+//	Limit = 10
+//
+// then
+//
+//	if err := agw.SetGroupConfigs(cfg.Http.Groups...); err != nil {
+//		return err
+//	}
+type GroupConfig struct {
+	Prefix string
+	// DisableLog skips the access log of the group
+	DisableLog bool
+	// DisableBodyLog skips body_in and body_out in the access log of the group
+	DisableBodyLog bool
+	// BodyLimit is the max size of request body, e.g. 4K, 2M, LimitConfig.BodyLimit if empty
+	BodyLimit string
+	// Timeout of the requests, LimitConfig.Timeout if 0
+	Timeout time.Duration `vx_default:"0s"`
+	// CORS overrides the CORS policy of the gateway if not nil
+	CORS *CORSConfig
+	// RateLimit overrides the rate limit of the gateway if not nil
+	RateLimit *RateLimitConfig
+	// Compress overrides the compression of the gateway if not nil
+	Compress *CompressConfig
+}
+
+// Router registers routes, implemented by ApiGateway and RouteGroup
+type Router interface {
+	Add(method, path string, handler echo.HandlerFunc, middleware ...echo.MiddlewareFunc) *echo.Route
+	gateway() *ApiGateway
+}
+
+// RouteGroup is a group of routes with its own middleware and GroupConfig
+type RouteGroup struct {
+	agw        *ApiGateway
+	prefix     string
+	middleware []echo.MiddlewareFunc
+}
+
+func (agw *ApiGateway) gateway() *ApiGateway {
+	return agw
+}
+
+func (g *RouteGroup) gateway() *ApiGateway {
+	return g.agw
+}
+
+// RouteGroup creates a group of routes under prefix with middleware m, the GroupConfig of prefix
+// set by SetGroupConfigs is applied. Unlike echo.Group, the middleware of the group is executed
+// for the routes of the group only, unmatched requests are handled by the gateway.
+func (agw *ApiGateway) RouteGroup(prefix string, m ...echo.MiddlewareFunc) *RouteGroup {
+	g := &RouteGroup{agw: agw, prefix: prefix}
+	g.middleware = append(g.middleware, m...)
+	return g
+}
+
+func (g *RouteGroup) Prefix() string {
+	return g.prefix
+}
+
+// Use appends middleware to the routes registered afterwards
+func (g *RouteGroup) Use(m ...echo.MiddlewareFunc) {
+	g.middleware = append(g.middleware, m...)
+}
+
+// Group creates a sub group inheriting the middleware of g
+func (g *RouteGroup) Group(prefix string, m ...echo.MiddlewareFunc) *RouteGroup {
+	return g.agw.RouteGroup(g.prefix+prefix, append(append([]echo.MiddlewareFunc{}, g.middleware...), m...)...)
+}
+
+// Add registers a route under the prefix of g with the middleware of g and m
+func (g *RouteGroup) Add(method, path string, handler echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	mw := make([]echo.MiddlewareFunc, 0, len(g.middleware)+len(m))
+	mw = append(append(mw, g.middleware...), m...)
+	return g.agw.Echo.Add(method, g.prefix+path, handler, mw...)
+}
+
+func (g *RouteGroup) GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.Add(http.MethodGet, path, h, m...)
+}
+
+func (g *RouteGroup) HEAD(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.Add(http.MethodHead, path, h, m...)
+}
+
+func (g *RouteGroup) POST(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.Add(http.MethodPost, path, h, m...)
+}
+
+func (g *RouteGroup) PUT(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.Add(http.MethodPut, path, h, m...)
+}
+
+func (g *RouteGroup) PATCH(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.Add(http.MethodPatch, path, h, m...)
+}
+
+func (g *RouteGroup) DELETE(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.Add(http.MethodDelete, path, h, m...)
+}
+
+func (g *RouteGroup) OPTIONS(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route {
+	return g.Add(http.MethodOptions, path, h, m...)
+}
+
+// SetGroupConfigs applies GroupConfig by prefix, it should be called before configEcho and RouteGroup.
+// It fails if any of gcs is invalid.
+func (agw *ApiGateway) SetGroupConfigs(gcs ...*GroupConfig) error {
+	if agw.groupConfs == nil {
+		agw.groupConfs = make(map[string]*GroupConfig)
+		agw.groupLimits = make(map[string]requestLimits)
+	}
+	for _, gc := range gcs {
+		n, err := parseBodyLimit(gc.BodyLimit)
+		if err != nil {
+			return errors.Wrapf(err, "body limit of group %v", gc.Prefix)
+		}
+		agw.groupConfs[gc.Prefix] = gc
+		agw.groupLimits[gc.Prefix] = requestLimits{body: n, timeout: gc.Timeout}
+		if gc.CORS != nil {
+			agw.SetGroupCORSConfig(gc.Prefix, gc.CORS)
+		}
+		if gc.RateLimit != nil {
+			if err := agw.SetGroupRateLimitConfig(gc.Prefix, gc.RateLimit); err != nil {
+				return err
+			}
+		}
+		if gc.Compress != nil {
+			if err := agw.SetGroupCompressConfig(gc.Prefix, gc.Compress); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// groupConfOf returns the GroupConfig of the longest prefix matching path
+func (agw *ApiGateway) groupConfOf(path string) *GroupConfig {
+	var matched *GroupConfig
+	for prefix, gc := range agw.groupConfs {
+		if pathHasPrefix(path, prefix) && (matched == nil || len(prefix) > len(matched.Prefix)) {
+			matched = gc
+		}
+	}
+	return matched
+}
@@ -0,0 +1,130 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/viperx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteGroup(t *testing.T) {
+	var out bytes.Buffer
+	agw := newTestApiGateway(t)
+	agw.LogConf.ContentFormatAfter = "${method} ${path} ${body_in} ${body_out}"
+	agw.LogConf.Timing = AccessLogAfterRun
+	agw.Logger.Out = &out
+	require.NoError(t, agw.SetGroupConfigs(
+		&GroupConfig{Prefix: "/admin", DisableBodyLog: true, BodyLimit: "8B",
+			CORS: &CORSConfig{AllowOrigins: []string{"https://admin.example.com"}}},
+		&GroupConfig{Prefix: "/admin/ping", DisableLog: true},
+	))
+	agw.configEcho()
+
+	auth := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Header.Get("X-Admin") == "" {
+				return SendResp(c, newErrResp(http.StatusForbidden, ""))
+			}
+			return next(c)
+		}
+	}
+	handler := func(c echo.Context) error { return SendResp(c, SuccessResp(c.Path())) }
+
+	admin := agw.RouteGroup("/admin", auth)
+	admin.POST("/users", handler)
+	admin.GET("/ping", handler)
+	Handle(admin, http.MethodGet, "/typed", func(ctx context.Context, req *struct{}) (*string, error) {
+		s := "typed"
+		return &s, nil
+	})
+	agw.RouteGroup("/api").POST("/users", handler)
+
+	// middleware of group
+	rec := serveTestRequest(agw, httptest.NewRequest(http.MethodPost, "/admin/users", strings.NewReader("{}")))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader("{}")))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/typed", nil)
+	req.Header.Set("X-Admin", "1")
+	rec = serveTestRequest(agw, req)
+	require.Contains(t, rec.Body.String(), `"Result":"typed"`)
+
+	// body limit of group
+	req = httptest.NewRequest(http.MethodPost, "/admin/users", strings.NewReader(`{"Name":"too long"}`))
+	req.Header.Set("X-Admin", "1")
+	rec = serveTestRequest(agw, req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	// cors of group
+	req = httptest.NewRequest(http.MethodPost, "/admin/users", nil)
+	req.Header.Set(echo.HeaderOrigin, "https://www.example.com")
+	rec = serveTestRequest(agw, req)
+	require.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	req = httptest.NewRequest(http.MethodPost, "/api/users", nil)
+	req.Header.Set(echo.HeaderOrigin, "https://www.example.com")
+	rec = serveTestRequest(agw, req)
+	require.Equal(t, "*", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+
+	// access log of group
+	out.Reset()
+	req = httptest.NewRequest(http.MethodPost, "/admin/users", strings.NewReader("{}"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-Admin", "1")
+	serveTestRequest(agw, req)
+	req = httptest.NewRequest(http.MethodGet, "/admin/ping", nil)
+	req.Header.Set("X-Admin", "1")
+	serveTestRequest(agw, req)
+	require.True(t, strings.HasPrefix(out.String(), "POST /admin/users in["), out.String())
+	require.NotContains(t, out.String(), "Result")
+	require.NotContains(t, out.String(), "/admin/ping")
+}
+
+func TestGroupConfigLoad(t *testing.T) {
+	var cfg struct {
+		Http struct {
+			RateLimit *RateLimitConfig
+			Groups    []*GroupConfig
+		}
+	}
+	loadTestConfig(t, &cfg, `
+[Http.RateLimit]
+Limit = 100
+
+[[Http.Groups]]
+Prefix = "/admin"
+DisableBodyLog = true
+BodyLimit = "1M"
+[Http.Groups.RateLimit]
+Limit = 10
+`)
+	require.Equal(t, 100, cfg.Http.RateLimit.Limit)
+	require.Len(t, cfg.Http.Groups, 1)
+	gc := cfg.Http.Groups[0]
+	require.Equal(t, "/admin", gc.Prefix)
+	require.True(t, gc.DisableBodyLog)
+	require.Equal(t, "1M", gc.BodyLimit)
+	require.Equal(t, 10, gc.RateLimit.Limit)
+
+	agw := newTestApiGateway(t)
+	require.NoError(t, agw.SetRateLimitConfig(cfg.Http.RateLimit))
+	require.NoError(t, agw.SetGroupConfigs(cfg.Http.Groups...))
+	agw.configEcho()
+}
+
+// loadTestConfig loads toml into cfg by viperx, the keys of cfg should be unique among the tests
+// since viperx is global
+func loadTestConfig(t *testing.T, cfg any, toml string) {
+	_, err := viperx.BindAllFlags(nil, cfg)
+	require.NoError(t, err)
+	v := viperx.GetViper()
+	v.SetConfigType("toml")
+	require.NoError(t, v.MergeConfig(strings.NewReader(toml)))
+	require.NoError(t, viperx.Unmarshal(cfg))
+}
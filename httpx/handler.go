@@ -0,0 +1,65 @@
+package httpx
+
+import (
+	"context"
+
+	"github.com/labstack/echo"
+)
+
+type echoContextKey struct{}
+
+// TypedHandlerFunc handles the request bound and validated by BindAndValidate, resp is
+// responded as Result of SuccessResp and err is responded by SendResp.
+type TypedHandlerFunc[Req, Resp any] func(ctx context.Context, req *Req) (resp *Resp, err error)
+
+// Responder is implemented by the responses of TypedHandlerFunc which render themselves,
+// e.g. files or raw content, instead of the JsonResponse envelope
+type Responder interface {
+	Respond(c echo.Context) error
+}
+
+// EchoContext returns the echo.Context of ctx passed to TypedHandlerFunc, e.g. to stream
+// the response, which is not enveloped once committed
+func EchoContext(ctx context.Context) echo.Context {
+	c, _ := ctx.Value(echoContextKey{}).(echo.Context)
+	return c
+}
+
+// TypedHandler adapts h to echo.HandlerFunc, binding errors are responded by BadRequestResp
+func TypedHandler[Req, Resp any](h TypedHandlerFunc[Req, Resp]) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(Req)
+		if err := BindAndValidate(c, req); err != nil {
+			return SendResp(c, BadRequestResp(err))
+		}
+
+		ctx := context.WithValue(c.Request().Context(), echoContextKey{}, c)
+		resp, err := h(ctx, req)
+		if err != nil {
+			return SendResp(c, err)
+		}
+		if c.Response().Committed {
+			return nil
+		}
+
+		if resp == nil {
+			return SendResp(c, SuccessResp(nil))
+		}
+		if r, ok := any(resp).(Responder); ok {
+			return r.Respond(c)
+		}
+		return SendResp(c, SuccessResp(resp))
+	}
+}
+
+// Handle registers h on method and path of r, i.e. ApiGateway or RouteGroup, Req and Resp are
+// documented in OpenAPI, e.g.
+//
+//	httpx.Handle(agw, http.MethodGet, "/v1/users", func(ctx context.Context, req *ListUsersReq) (*[]User, error) {
+//		...
+//	})
+func Handle[Req, Resp any](r Router, method, path string, h TypedHandlerFunc[Req, Resp],
+	m ...echo.MiddlewareFunc) *echo.Route {
+	route := r.Add(method, path, TypedHandler(h), m...)
+	return r.gateway().Document(route, RouteDoc{Request: new(Req), Response: new(Resp)})
+}
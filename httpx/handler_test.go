@@ -0,0 +1,73 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+type testGreetReq struct {
+	Name string `hx_place:"query" hx_name:"name" hx_must:"true"`
+}
+
+type testGreetResp struct {
+	Greeting string
+}
+
+type testRawResp string
+
+func (r *testRawResp) Respond(c echo.Context) error {
+	return c.String(http.StatusOK, string(*r))
+}
+
+func TestTypedHandler(t *testing.T) {
+	agw := newTestApiGateway(t)
+	agw.configEcho()
+
+	Handle(agw, http.MethodGet, "/v1/greet", func(ctx context.Context, req *testGreetReq) (*testGreetResp, error) {
+		require.NotNil(t, EchoContext(ctx))
+		if req.Name == "nobody" {
+			return nil, newErrResp(http.StatusNotFound, "")
+		}
+		return &testGreetResp{Greeting: "hello " + req.Name}, nil
+	})
+	Handle(agw, http.MethodGet, "/v1/raw", func(ctx context.Context, req *struct{}) (*testRawResp, error) {
+		resp := testRawResp("raw")
+		return &resp, nil
+	})
+	Handle(agw, http.MethodGet, "/v1/stream", func(ctx context.Context, req *struct{}) (*struct{}, error) {
+		c := EchoContext(ctx)
+		c.Response().WriteHeader(http.StatusAccepted)
+		_, err := c.Response().Write([]byte("streamed"))
+		return nil, err
+	})
+
+	rec := serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/v1/greet?name=bob", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"Result":{"Greeting":"hello bob"}`)
+
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/v1/greet", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), `"Message":"missing query parameter Name"`)
+
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/v1/greet?name=nobody", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/v1/raw", nil))
+	require.Equal(t, "raw", rec.Body.String())
+
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/v1/stream", nil))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.Equal(t, "streamed", rec.Body.String())
+
+	// Req and Resp are documented
+	b, err := agw.OpenAPIJSON()
+	require.NoError(t, err)
+	require.True(t, strings.Contains(string(b), `"name": "name"`))
+	require.True(t, strings.Contains(string(b), `"#/components/schemas/testGreetResp"`))
+}
@@ -0,0 +1,207 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/errors"
+)
+
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+
+	defaultHealthCheckTimeout = 3 * time.Second
+)
+
+// HealthConfig serves liveness and readiness of the HealthRegistry on ApiGateway
+type HealthConfig struct {
+	Enable        bool
+	LivenessPath  string `vx_default:"/healthz"`
+	ReadinessPath string `vx_default:"/readyz"`
+}
+
+// HealthChecker checks one dependency, e.g. database or downstream service
+type HealthChecker struct {
+	Name  string
+	Check func(ctx context.Context) error
+	// Timeout of Check, 3s by default
+	Timeout time.Duration
+	// Critical checker fails the report, others are reported only
+	Critical bool
+	// Liveness checker takes part in liveness as well, readiness runs all checkers
+	Liveness bool
+}
+
+type HealthCheckResult struct {
+	Name     string
+	Status   string
+	Critical bool
+	Latency  string
+	Error    string `json:",omitempty"`
+}
+
+type HealthReport struct {
+	Status       string
+	ShuttingDown bool                `json:",omitempty"`
+	Checks       []HealthCheckResult `json:",omitempty"`
+}
+
+func (hr *HealthReport) IsOK() bool {
+	return hr.Status == HealthStatusOK
+}
+
+// HealthRegistry holds the checkers. Readiness fails once shutting down, so that load
+// balancers stop sending traffic before services are stopped.
+type HealthRegistry struct {
+	mu           sync.RWMutex
+	checkers     []HealthChecker
+	shuttingDown atomic.Bool
+}
+
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{}
+}
+
+func (hr *HealthRegistry) Register(hc HealthChecker) error {
+	if hc.Name == "" || hc.Check == nil {
+		return errors.Errorf("invalid health checker:%v", hc.Name)
+	}
+
+	if hc.Timeout <= 0 {
+		hc.Timeout = defaultHealthCheckTimeout
+	}
+
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	for _, c := range hr.checkers {
+		if c.Name == hc.Name {
+			return errors.Errorf("duplicated health checker:%v", hc.Name)
+		}
+	}
+	hr.checkers = append(hr.checkers, hc)
+	return nil
+}
+
+// WatchContext fails readiness once ctx is done, e.g. graceful.Graceful.Context()
+func (hr *HealthRegistry) WatchContext(ctx context.Context) {
+	if ctx.Done() == nil {
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		hr.SetShuttingDown()
+	}()
+}
+
+func (hr *HealthRegistry) SetShuttingDown() {
+	hr.shuttingDown.Store(true)
+}
+
+func (hr *HealthRegistry) IsShuttingDown() bool {
+	return hr.shuttingDown.Load()
+}
+
+// Liveness runs the checkers with Liveness set, it is not impacted by shutting down
+func (hr *HealthRegistry) Liveness(ctx context.Context) *HealthReport {
+	return hr.run(ctx, true)
+}
+
+// Readiness runs all the checkers, and fails if shutting down
+func (hr *HealthRegistry) Readiness(ctx context.Context) *HealthReport {
+	report := hr.run(ctx, false)
+	if hr.IsShuttingDown() {
+		report.ShuttingDown = true
+		report.Status = HealthStatusFail
+	}
+	return report
+}
+
+func (hr *HealthRegistry) run(ctx context.Context, livenessOnly bool) *HealthReport {
+	hr.mu.RLock()
+	checkers := make([]HealthChecker, 0, len(hr.checkers))
+	for _, hc := range hr.checkers {
+		if !livenessOnly || hc.Liveness {
+			checkers = append(checkers, hc)
+		}
+	}
+	hr.mu.RUnlock()
+
+	report := &HealthReport{
+		Status: HealthStatusOK,
+		Checks: make([]HealthCheckResult, len(checkers)),
+	}
+
+	var wg sync.WaitGroup
+	for i := range checkers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report.Checks[i] = runHealthChecker(ctx, checkers[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, r := range report.Checks {
+		if r.Critical && r.Status != HealthStatusOK {
+			report.Status = HealthStatusFail
+		}
+	}
+
+	return report
+}
+
+func runHealthChecker(ctx context.Context, hc HealthChecker) HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, hc.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- errors.Errorf("panic: %v", r)
+			}
+		}()
+		errCh <- hc.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := HealthCheckResult{
+		Name:     hc.Name,
+		Status:   HealthStatusOK,
+		Critical: hc.Critical,
+		Latency:  time.Since(start).String(),
+	}
+	if err != nil {
+		result.Status = HealthStatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (hr *HealthRegistry) handler(readiness bool) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var report *HealthReport
+		if readiness {
+			report = hr.Readiness(c.Request().Context())
+		} else {
+			report = hr.Liveness(c.Request().Context())
+		}
+
+		if report.IsOK() {
+			return SendResp(c, SuccessResp(report))
+		}
+		return SendResp(c, newErrResp(http.StatusServiceUnavailable, "").WithResult(report))
+	}
+}
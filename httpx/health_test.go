@@ -0,0 +1,79 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/madlabx/pkgx/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthRegistry(t *testing.T) {
+	hr := NewHealthRegistry()
+	require.NoError(t, hr.Register(HealthChecker{
+		Name:     "db",
+		Critical: true,
+		Liveness: true,
+		Check:    func(ctx context.Context) error { return nil },
+	}))
+	require.NoError(t, hr.Register(HealthChecker{
+		Name:  "cache",
+		Check: func(ctx context.Context) error { return errors.New("unreachable") },
+	}))
+	require.Error(t, hr.Register(HealthChecker{Name: "db", Check: func(ctx context.Context) error { return nil }}))
+
+	// non critical failure is reported only
+	report := hr.Readiness(context.Background())
+	require.True(t, report.IsOK())
+	require.Len(t, report.Checks, 2)
+	require.Equal(t, HealthStatusFail, report.Checks[1].Status)
+	require.Equal(t, "unreachable", report.Checks[1].Error)
+
+	require.NoError(t, hr.Register(HealthChecker{
+		Name:     "slow",
+		Critical: true,
+		Timeout:  10 * time.Millisecond,
+		Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}))
+	report = hr.Readiness(context.Background())
+	require.False(t, report.IsOK())
+	require.Equal(t, context.DeadlineExceeded.Error(), report.Checks[2].Error)
+
+	// liveness runs liveness checkers only
+	report = hr.Liveness(context.Background())
+	require.True(t, report.IsOK())
+	require.Len(t, report.Checks, 1)
+}
+
+func TestApiGatewayHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	agw, err := NewApiGateway(ctx, "127.0.0.1", "0", "test", newTestApiGateway(t).LogConf, nil)
+	require.NoError(t, err)
+	agw.SetHealthConfig(&HealthConfig{Enable: true})
+	agw.configEcho()
+
+	rec := serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	cancel()
+	require.Eventually(t, agw.HealthRegistry().IsShuttingDown, time.Second, time.Millisecond)
+
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var jr struct {
+		Result HealthReport
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jr))
+	require.True(t, jr.Result.ShuttingDown)
+
+	// liveness is not impacted by shutting down
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
@@ -1,6 +1,7 @@
 package httpx
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net"
@@ -15,6 +16,7 @@ import (
 	resty "github.com/go-resty/resty/v2"
 	"github.com/madlabx/pkgx/errors"
 	"github.com/madlabx/pkgx/log"
+	"github.com/madlabx/pkgx/metricx"
 	"github.com/madlabx/pkgx/typex"
 )
 
@@ -106,6 +108,7 @@ type JsonClient struct {
 	Timeout int // in milliseconds
 
 	statsChan chan<- *RequestStats
+	metrics   *clientMetrics
 	c         *resty.Client
 }
 
@@ -136,6 +139,11 @@ func (c *JsonClient) SetStatsChan(ch chan<- *RequestStats) {
 	c.statsChan = ch
 }
 
+// SetMetrics records requests and latencies into reg
+func (c *JsonClient) SetMetrics(reg *metricx.Registry) {
+	c.metrics = newClientMetrics(reg)
+}
+
 func (c *JsonClient) SetReuseConnection() {
 
 	log.Infof("Set client %s:%d reuse connection", c.Host, c.Port)
@@ -227,7 +235,20 @@ func (c *JsonClient) StatusError(method, url string, rsp *resty.Response) *Statu
 func (c *JsonClient) RequestTimeout(method, url string, headers map[string]string,
 	data interface{}, timeout int) (typex.JsonMap, error) {
 
-	rsp, err := c.requestRTimeout(typex.JsonMap{}, method, url, headers, data, timeout)
+	return c.requestTimeout(context.Background(), method, url, headers, data, timeout)
+}
+
+// RequestCtx works as Request, and the request id carried by ctx is sent as X-Request-ID
+func (c *JsonClient) RequestCtx(ctx context.Context, method, url string, headers map[string]string,
+	data interface{}) (typex.JsonMap, error) {
+
+	return c.requestTimeout(ctx, method, url, headers, data, -1)
+}
+
+func (c *JsonClient) requestTimeout(ctx context.Context, method, url string, headers map[string]string,
+	data interface{}, timeout int) (typex.JsonMap, error) {
+
+	rsp, err := c.requestRTimeout(ctx, typex.JsonMap{}, method, url, headers, data, timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -247,10 +268,17 @@ func (c *JsonClient) RequestTimeout(method, url string, headers map[string]strin
 func (c *JsonClient) RequestR(result interface{}, method, url string, headers map[string]string,
 	data interface{}) (*resty.Response, error) {
 
-	return c.requestRTimeout(result, method, url, headers, data, -1)
+	return c.requestRTimeout(context.Background(), result, method, url, headers, data, -1)
+}
+
+// RequestRCtx works as RequestR, and the request id carried by ctx is sent as X-Request-ID
+func (c *JsonClient) RequestRCtx(ctx context.Context, result interface{}, method, url string, headers map[string]string,
+	data interface{}) (*resty.Response, error) {
+
+	return c.requestRTimeout(ctx, result, method, url, headers, data, -1)
 }
 
-func (c *JsonClient) requestRTimeout(result interface{}, method, url string, headers map[string]string,
+func (c *JsonClient) requestRTimeout(ctx context.Context, result interface{}, method, url string, headers map[string]string,
 	data interface{}, timeout int) (*resty.Response, error) {
 
 	hc := c.c
@@ -292,6 +320,10 @@ func (c *JsonClient) requestRTimeout(result interface{}, method, url string, hea
 		}
 	}
 	r.SetHeaders(headers)
+	r.SetContext(ctx)
+	if rid := log.RequestIdFromContext(ctx); rid != "" && r.Header.Get("X-Request-ID") == "" {
+		r.SetHeader("X-Request-ID", rid)
+	}
 	if data != nil {
 		if headers["Content-Type"] == "application/x-www-form-urlencoded" {
 			r.SetFormData(data.(map[string]string))
@@ -307,13 +339,16 @@ func (c *JsonClient) requestRTimeout(result interface{}, method, url string, hea
 		}
 		url = c.Url(url)
 	}
-	log.Debugf("Send api request: %s %s, timeout: %d", method, url, timeout)
+	log.WithContext(ctx).Debugf("Send api request: %s %s, timeout: %d", method, url, timeout)
 	if c.IsHttps {
 		stats.Scheme = "https"
 	}
 	defer func() {
 		stats.PreTime = stats.SendTime.Sub(stats.ReqTime).Nanoseconds() / 1000
 		stats.Latency = stats.RspTime.Sub(stats.ReqTime).Nanoseconds() / 1000
+		if c.metrics != nil {
+			c.metrics.observe(stats)
+		}
 		if c.statsChan != nil {
 			c.statsChan <- stats
 		}
@@ -0,0 +1,280 @@
+package httpx
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/madlabx/pkgx/errors"
+)
+
+const (
+	// codes of errcode.ErrInvalidJwt etc., httpx could not import errcode
+	codeInvalidJwt      = "InvalidJwt"
+	codeExpiredToken    = "ExpiredToken"
+	codeInvalidIssuer   = "InvalidIssuer"
+	codeUnmatchedJwtKey = "UnmatchedJwtKey"
+
+	jwtClaimsContextKey = "httpx.jwt_claims"
+)
+
+var (
+	errUnmatchedJwtKey = errors.New("unmatched jwt key")
+)
+
+// JWTClaimsIf is implemented by JWTClaims and the structs embedding it, e.g.
+//
+//	type UserClaims struct {
+//		httpx.JWTClaims
+//		UserId string `json:"uid"`
+//	}
+type JWTClaimsIf interface {
+	jwt.Claims
+	standard() *JWTClaims
+}
+
+// JWTClaims holds the registered claims. Expiry, not-before, issuer and audience are
+// verified by the JWT middleware with JWTConfig.Leeway, Valid could be overridden for
+// additional checks of the custom claims.
+type JWTClaims struct {
+	jwt.StandardClaims
+}
+
+func (jc *JWTClaims) standard() *JWTClaims {
+	return jc
+}
+
+// Valid is called after the registered claims are verified
+func (jc *JWTClaims) Valid() error {
+	return nil
+}
+
+// JWTConfig defines the config for JWT middleware.
+type JWTConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// SigningKey verifies tokens without kid or with kid not in SigningKeys, []byte for HMAC,
+	// *rsa.PublicKey or *ecdsa.PublicKey
+	SigningKey any
+	// SigningKeys verifies tokens by kid in header, e.g. during key rotation
+	SigningKeys map[string]any
+	// SigningMethods limits alg of tokens, all methods matching the key types if empty
+	SigningMethods []string
+
+	// Issuer must equal to iss if not empty
+	Issuer string
+	// Audience must contain aud if not empty
+	Audience []string
+	// Leeway tolerates the clock skew on exp, nbf and iat
+	Leeway time.Duration
+
+	// TokenLookup is "header:<name>", "query:<name>" or "cookie:<name>"
+	// Optional. Default value "header:Authorization".
+	TokenLookup string
+	// AuthScheme is the prefix of token in header
+	// Optional. Default value "Bearer".
+	AuthScheme string
+
+	// NewClaims returns the claims to decode tokens into
+	// Optional. Default value returns &JWTClaims{}.
+	NewClaims func() JWTClaimsIf
+}
+
+// JWT returns a JWT middleware verifying bearer tokens with key.
+func JWT(key any) echo.MiddlewareFunc {
+	return JWTWithConfig(JWTConfig{SigningKey: key})
+}
+
+// JWTWithConfig returns a JWT middleware with config, the claims are available by GetJWTClaims.
+// Failures are responded with the JsonResponse of errcode.ErrInvalidJwt, ErrExpiredToken,
+// ErrInvalidIssuer or ErrUnmatchedJwtKey.
+// It panics if there is no key.
+func JWTWithConfig(config JWTConfig) echo.MiddlewareFunc {
+	if config.SigningKey == nil && len(config.SigningKeys) == 0 {
+		panic("jwt middleware requires signing key")
+	}
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.TokenLookup == "" {
+		config.TokenLookup = "header:" + echo.HeaderAuthorization
+	}
+	if config.AuthScheme == "" {
+		config.AuthScheme = "Bearer"
+	}
+	if config.NewClaims == nil {
+		config.NewClaims = func() JWTClaimsIf { return &JWTClaims{} }
+	}
+	if len(config.SigningMethods) == 0 {
+		config.SigningMethods = jwtMethodsOfKeys(config.SigningKey, config.SigningKeys)
+	}
+
+	extractor := jwtTokenExtractor(config.TokenLookup, config.AuthScheme)
+	parser := &jwt.Parser{ValidMethods: config.SigningMethods, SkipClaimsValidation: true}
+	keyFunc := func(t *jwt.Token) (any, error) {
+		key := config.SigningKey
+		if kid, ok := t.Header["kid"].(string); ok && kid != "" {
+			if k, ok := config.SigningKeys[kid]; ok {
+				key = k
+			}
+		}
+		if key == nil || !jwtKeyMatchMethod(key, t.Method) {
+			return nil, errUnmatchedJwtKey
+		}
+		return key, nil
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			raw := extractor(c)
+			if raw == "" {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, config.AuthScheme)
+				return SendResp(c, newErrResp(http.StatusUnauthorized, codeInvalidJwt).WithMsgf("missing jwt"))
+			}
+
+			claims := config.NewClaims()
+			if _, err := parser.ParseWithClaims(raw, claims, keyFunc); err != nil {
+				code := codeInvalidJwt
+				var ve *jwt.ValidationError
+				if errors.As(err, &ve) && ve.Inner == errUnmatchedJwtKey {
+					code = codeUnmatchedJwtKey
+				}
+				return SendResp(c, newErrResp(http.StatusUnauthorized, code).WithError(err))
+			}
+
+			if code, err := verifyJWTClaims(&config, claims); err != nil {
+				return SendResp(c, newErrResp(http.StatusUnauthorized, code).WithError(err))
+			}
+
+			c.Set(jwtClaimsContextKey, claims)
+			return next(c)
+		}
+	}
+}
+
+func verifyJWTClaims(config *JWTConfig, claims JWTClaimsIf) (string, error) {
+	sc := &claims.standard().StandardClaims
+	now := time.Now()
+	leeway := int64(config.Leeway / time.Second)
+
+	if sc.ExpiresAt != 0 && now.Unix() > sc.ExpiresAt+leeway {
+		return codeExpiredToken, errors.Errorf("token is expired by %v", now.Sub(time.Unix(sc.ExpiresAt, 0)))
+	}
+	if sc.NotBefore != 0 && now.Unix() < sc.NotBefore-leeway {
+		return codeInvalidJwt, errors.New("token is not valid yet")
+	}
+	if sc.IssuedAt != 0 && now.Unix() < sc.IssuedAt-leeway {
+		return codeInvalidJwt, errors.New("token used before issued")
+	}
+	if config.Issuer != "" && sc.Issuer != config.Issuer {
+		return codeInvalidIssuer, errors.Errorf("unexpected issuer:%v", sc.Issuer)
+	}
+	if len(config.Audience) > 0 {
+		matched := false
+		for _, aud := range config.Audience {
+			if sc.Audience == aud {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return codeInvalidJwt, errors.Errorf("unexpected audience:%v", sc.Audience)
+		}
+	}
+
+	if err := claims.Valid(); err != nil {
+		return codeInvalidJwt, err
+	}
+	return "", nil
+}
+
+func jwtTokenExtractor(lookup, scheme string) func(c echo.Context) string {
+	source, name, _ := strings.Cut(lookup, ":")
+	switch source {
+	case "query":
+		return func(c echo.Context) string { return c.QueryParam(name) }
+	case "cookie":
+		return func(c echo.Context) string {
+			cookie, err := c.Cookie(name)
+			if err != nil {
+				return ""
+			}
+			return cookie.Value
+		}
+	default:
+		return func(c echo.Context) string {
+			auth := c.Request().Header.Get(name)
+			if len(auth) > len(scheme) && strings.EqualFold(auth[:len(scheme)], scheme) && auth[len(scheme)] == ' ' {
+				return strings.TrimSpace(auth[len(scheme)+1:])
+			}
+			return ""
+		}
+	}
+}
+
+func jwtMethodsOfKeys(key any, keys map[string]any) []string {
+	methods := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(k any) {
+		for _, m := range []jwt.SigningMethod{
+			jwt.SigningMethodHS256, jwt.SigningMethodHS384, jwt.SigningMethodHS512,
+			jwt.SigningMethodRS256, jwt.SigningMethodRS384, jwt.SigningMethodRS512,
+			jwt.SigningMethodPS256, jwt.SigningMethodPS384, jwt.SigningMethodPS512,
+			jwt.SigningMethodES256, jwt.SigningMethodES384, jwt.SigningMethodES512,
+		} {
+			if !seen[m.Alg()] && jwtKeyMatchMethod(k, m) {
+				seen[m.Alg()] = true
+				methods = append(methods, m.Alg())
+			}
+		}
+	}
+
+	if key != nil {
+		add(key)
+	}
+	for _, k := range keys {
+		add(k)
+	}
+	return methods
+}
+
+// jwtKeyMatchMethod avoids the confusion of algorithms, e.g. a RSA public key used as HMAC secret
+func jwtKeyMatchMethod(key any, m jwt.SigningMethod) bool {
+	switch m.(type) {
+	case *jwt.SigningMethodHMAC:
+		_, ok := key.([]byte)
+		return ok
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok := key.(*rsa.PublicKey)
+		return ok
+	case *jwt.SigningMethodECDSA:
+		_, ok := key.(*ecdsa.PublicKey)
+		return ok
+	default:
+		return false
+	}
+}
+
+// GetJWTClaims returns the claims verified by JWT middleware, nil if absent
+func GetJWTClaims(c echo.Context) JWTClaimsIf {
+	claims, _ := c.Get(jwtClaimsContextKey).(JWTClaimsIf)
+	return claims
+}
+
+// GetJWTClaimsAs returns the claims of type T verified by JWT middleware, e.g.
+//
+//	claims, ok := httpx.GetJWTClaimsAs[*UserClaims](c)
+func GetJWTClaimsAs[T JWTClaimsIf](c echo.Context) (T, bool) {
+	claims, ok := c.Get(jwtClaimsContextKey).(T)
+	return claims, ok
+}
@@ -0,0 +1,131 @@
+package httpx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+type testUserClaims struct {
+	JWTClaims
+	UserId string `json:"uid"`
+}
+
+func signTestJwt(t *testing.T, m jwt.SigningMethod, key any, kid string, claims jwt.Claims) string {
+	token := jwt.NewWithClaims(m, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func TestJWT(t *testing.T) {
+	secret := []byte("secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	agw := newTestApiGateway(t)
+	agw.configEcho()
+	agw.GET("/v1/me", func(c echo.Context) error {
+		claims, ok := GetJWTClaimsAs[*testUserClaims](c)
+		require.True(t, ok)
+		return SendResp(c, SuccessResp(claims.UserId))
+	}, JWTWithConfig(JWTConfig{
+		SigningKey:  secret,
+		SigningKeys: map[string]any{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey},
+		Issuer:      "pkgx",
+		Audience:    []string{"web", "app"},
+		Leeway:      time.Minute,
+		NewClaims:   func() JWTClaimsIf { return &testUserClaims{} },
+	}))
+
+	newClaims := func(modify func(c *testUserClaims)) *testUserClaims {
+		c := &testUserClaims{UserId: "u1"}
+		c.Issuer = "pkgx"
+		c.Audience = "app"
+		c.ExpiresAt = time.Now().Add(time.Hour).Unix()
+		if modify != nil {
+			modify(c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+		code   string
+	}{
+		{"hmac", signTestJwt(t, jwt.SigningMethodHS256, secret, "", newClaims(nil)), http.StatusOK, ""},
+		{"rsa", signTestJwt(t, jwt.SigningMethodRS256, rsaKey, "rsa", newClaims(nil)), http.StatusOK, ""},
+		{"ecdsa", signTestJwt(t, jwt.SigningMethodES256, ecKey, "ec", newClaims(nil)), http.StatusOK, ""},
+		{"clock skew", signTestJwt(t, jwt.SigningMethodHS256, secret, "", newClaims(func(c *testUserClaims) {
+			c.ExpiresAt = time.Now().Add(-30 * time.Second).Unix()
+		})), http.StatusOK, ""},
+		{"hmac with unknown kid", signTestJwt(t, jwt.SigningMethodHS256, secret, "k1", newClaims(nil)), http.StatusOK, ""},
+		{"missing", "", http.StatusUnauthorized, codeInvalidJwt},
+		{"wrong secret", signTestJwt(t, jwt.SigningMethodHS256, []byte("other"), "", newClaims(nil)),
+			http.StatusUnauthorized, codeInvalidJwt},
+		{"expired", signTestJwt(t, jwt.SigningMethodHS256, secret, "", newClaims(func(c *testUserClaims) {
+			c.ExpiresAt = time.Now().Add(-2 * time.Minute).Unix()
+		})), http.StatusUnauthorized, codeExpiredToken},
+		{"issuer", signTestJwt(t, jwt.SigningMethodHS256, secret, "", newClaims(func(c *testUserClaims) {
+			c.Issuer = "other"
+		})), http.StatusUnauthorized, codeInvalidIssuer},
+		{"audience", signTestJwt(t, jwt.SigningMethodHS256, secret, "", newClaims(func(c *testUserClaims) {
+			c.Audience = "other"
+		})), http.StatusUnauthorized, codeInvalidJwt},
+		{"unknown kid", signTestJwt(t, jwt.SigningMethodRS256, rsaKey, "unknown", newClaims(nil)),
+			http.StatusUnauthorized, codeUnmatchedJwtKey},
+		{"rsa key as hmac secret", signTestJwt(t, jwt.SigningMethodHS256, []byte("x"), "rsa", newClaims(nil)),
+			http.StatusUnauthorized, codeUnmatchedJwtKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/me", nil)
+			if tt.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			rec := serveTestRequest(agw, req)
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+
+			var jr JsonResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jr))
+			if tt.code != "" {
+				require.Equal(t, tt.code, jr.Code)
+			} else {
+				require.Equal(t, "u1", jr.Result)
+			}
+		})
+	}
+}
+
+func TestJWTSigningKeyWithKid(t *testing.T) {
+	secret := []byte("secret")
+	agw := newTestApiGateway(t)
+	agw.configEcho()
+	agw.GET("/v1/me", func(c echo.Context) error {
+		return SendResp(c, SuccessResp(nil))
+	}, JWT(secret))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/me", nil)
+	token := signTestJwt(t, jwt.SigningMethodHS256, secret, "2024-01", &JWTClaims{})
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := serveTestRequest(agw, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
@@ -0,0 +1,340 @@
+package httpx
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/labstack/gommon/bytes"
+	"github.com/madlabx/pkgx/errors"
+)
+
+// LimitConfig is the default limits of the requests, which are overridden by GroupConfig.BodyLimit
+// and GroupConfig.Timeout of the longest matched prefix, a prefix could be the path of a single route.
+type LimitConfig struct {
+	// BodyLimit is the max size of request body, e.g. 4K, 2M, no limit if empty
+	BodyLimit string
+	// Timeout cancels the context of the request and responds 504, no timeout if 0
+	Timeout time.Duration `vx_default:"0s"`
+}
+
+// BodyLimitConfig defines the config for BodyLimit middleware.
+type BodyLimitConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// Limit is the max size of request body, e.g. 4K, 2M
+	Limit string
+}
+
+// TimeoutConfig defines the config for Timeout middleware.
+type TimeoutConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// Timeout of the handler, no timeout if 0
+	Timeout time.Duration
+}
+
+// limitedBody fails the reading beyond limit, unlike http.MaxBytesReader it remembers the
+// failure to respond 413 even if the error is swallowed by handler
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+	exceeded  bool
+}
+
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	if lb.exceeded {
+		return 0, &http.MaxBytesError{Limit: lb.limit}
+	}
+	if int64(len(p)) > lb.remaining+1 {
+		p = p[:lb.remaining+1]
+	}
+	n, err := lb.ReadCloser.Read(p)
+	if int64(n) > lb.remaining {
+		lb.exceeded = true
+		return int(lb.remaining), &http.MaxBytesError{Limit: lb.limit}
+	}
+	lb.remaining -= int64(n)
+	return n, err
+}
+
+func parseBodyLimit(limit string) (int64, error) {
+	if limit == "" {
+		return 0, nil
+	}
+	n, err := bytes.Parse(limit)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid body limit:%v", limit)
+	}
+	return n, nil
+}
+
+func bodyTooLarge(c echo.Context, limit int64) error {
+	return SendResp(c, newErrResp(http.StatusRequestEntityTooLarge, "").
+		WithMsgf("request body exceeds %v", bytes.Format(limit)))
+}
+
+// handleBodyLimit rejects the request with Content-Length beyond limit before reading, the body of
+// unknown length is limited while reading
+func handleBodyLimit(c echo.Context, limit int64, next echo.HandlerFunc) error {
+	req := c.Request()
+	if req.ContentLength > limit {
+		return bodyTooLarge(c, limit)
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return next(c)
+	}
+
+	lb := &limitedBody{ReadCloser: req.Body, remaining: limit, limit: limit}
+	req.Body = lb
+	err := next(c)
+
+	var mbe *http.MaxBytesError
+	if !c.Response().Committed && (lb.exceeded || errors.As(err, &mbe)) {
+		return bodyTooLarge(c, limit)
+	}
+	return err
+}
+
+// timeoutWriter buffers the response of the handler run by handleTimeout, the writes after timeout
+// are discarded
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	status   int
+	body     []byte
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.status == 0 {
+		tw.status = status
+	}
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	tw.body = append(tw.body, b...)
+	return len(b), nil
+}
+
+// Flush does nothing since the response is sent after the handler returns
+func (tw *timeoutWriter) Flush() {}
+
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.Errorf("hijack is not supported with timeout")
+}
+
+// flushTo sends the buffered response to w
+func (tw *timeoutWriter) flushTo(w http.ResponseWriter) {
+	header := w.Header()
+	for k := range header {
+		if _, ok := tw.header[k]; !ok {
+			delete(header, k)
+		}
+	}
+	for k, v := range tw.header {
+		header[k] = v
+	}
+	if tw.status != 0 {
+		w.WriteHeader(tw.status)
+		_, _ = w.Write(tw.body)
+	}
+}
+
+type timeoutResult struct {
+	err       error
+	recovered any
+}
+
+// handleTimeout runs the handler with the context cancelled after timeout, and responds 504 on time
+// even if the handler ignores the context. The response of the handler is buffered, so that Flush
+// and Hijack are not supported, and discarded after timeout. It returns after the handler does
+// since echo.Context could not be used after return, so the handler should still return once
+// the context is done, e.g. by passing it to the calls of database or upstream.
+func handleTimeout(c echo.Context, timeout time.Duration, next echo.HandlerFunc) error {
+	req := c.Request()
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+	c.SetRequest(req.WithContext(ctx))
+
+	res := c.Response()
+	w := res.Writer
+	tw := &timeoutWriter{header: w.Header().Clone()}
+	res.Writer = tw
+
+	done := make(chan timeoutResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- timeoutResult{recovered: r}
+			}
+		}()
+		done <- timeoutResult{err: next(c)}
+	}()
+
+	var r timeoutResult
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		if ctx.Err() != context.DeadlineExceeded {
+			r = <-done
+			break
+		}
+		tw.mu.Lock()
+		tw.timedOut = true
+		tw.mu.Unlock()
+
+		// respond by w directly since c is still used by the handler
+		resp := &timeoutWriter{header: w.Header()}
+		_ = WriteResp(resp, req, newErrResp(http.StatusGatewayTimeout, "").
+			WithMsgf("request timeout after %v", timeout))
+		w.Header().Set(echo.HeaderContentLength, strconv.Itoa(len(resp.body)))
+		w.WriteHeader(resp.status)
+		_, _ = w.Write(resp.body)
+		_ = http.NewResponseController(w).Flush()
+
+		r = <-done
+		res.Writer = w
+		res.Status, res.Size, res.Committed = resp.status, int64(len(resp.body)), true
+		if r.recovered != nil {
+			panic(r.recovered)
+		}
+		return nil
+	}
+
+	res.Writer = w
+	if r.recovered != nil {
+		panic(r.recovered)
+	}
+	tw.flushTo(w)
+	if ctx.Err() == context.DeadlineExceeded && !res.Committed &&
+		(r.err == nil || errors.Is(r.err, context.DeadlineExceeded)) {
+		return SendResp(c, newErrResp(http.StatusGatewayTimeout, "").
+			WithMsgf("request timeout after %v", timeout))
+	}
+	return r.err
+}
+
+// BodyLimit returns a BodyLimit middleware with limit, e.g. 4K, 2M.
+func BodyLimit(limit string) echo.MiddlewareFunc {
+	return BodyLimitWithConfig(BodyLimitConfig{Limit: limit})
+}
+
+// BodyLimitWithConfig returns a BodyLimit middleware which responds 413 if the request body exceeds
+// config.Limit. Being a route middleware, it could lower the limit of LimitConfig only.
+func BodyLimitWithConfig(config BodyLimitConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	limit, err := parseBodyLimit(config.Limit)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) || limit <= 0 {
+				return next(c)
+			}
+			return handleBodyLimit(c, limit, next)
+		}
+	}
+}
+
+// Timeout returns a Timeout middleware with timeout.
+func Timeout(timeout time.Duration) echo.MiddlewareFunc {
+	return TimeoutWithConfig(TimeoutConfig{Timeout: timeout})
+}
+
+// TimeoutWithConfig returns a Timeout middleware which cancels the context of the request after
+// config.Timeout and responds 504.
+func TimeoutWithConfig(config TimeoutConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) || config.Timeout <= 0 {
+				return next(c)
+			}
+			return handleTimeout(c, config.Timeout, next)
+		}
+	}
+}
+
+// requestLimits are the parsed LimitConfig and GroupConfig
+type requestLimits struct {
+	body    int64
+	timeout time.Duration
+}
+
+// SetLimitConfig sets the default body limit and timeout of the requests, it fails if lc is invalid
+func (agw *ApiGateway) SetLimitConfig(lc *LimitConfig) error {
+	if lc == nil {
+		agw.limits = requestLimits{}
+		return nil
+	}
+	n, err := parseBodyLimit(lc.BodyLimit)
+	if err != nil {
+		return err
+	}
+	agw.limits = requestLimits{body: n, timeout: lc.Timeout}
+	return nil
+}
+
+func (agw *ApiGateway) limitMiddleware() echo.MiddlewareFunc {
+	defLimits := agw.limits
+	groupLimits := make(map[string]requestLimits, len(agw.groupLimits))
+	for prefix, l := range agw.groupLimits {
+		if l.body == 0 {
+			l.body = defLimits.body
+		}
+		if l.timeout == 0 {
+			l.timeout = defLimits.timeout
+		}
+		groupLimits[prefix] = l
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			l := defLimits
+			if gc := agw.groupConfOf(c.Request().URL.Path); gc != nil {
+				l = groupLimits[gc.Prefix]
+			}
+
+			h := next
+			if l.timeout > 0 {
+				h = func(c echo.Context) error { return handleTimeout(c, l.timeout, next) }
+			}
+			if l.body > 0 {
+				return handleBodyLimit(c, l.body, h)
+			}
+			return h(c)
+		}
+	}
+}
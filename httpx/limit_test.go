@@ -0,0 +1,170 @@
+package httpx
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitConfigLoad(t *testing.T) {
+	var cfg struct {
+		LimitLoad struct {
+			Limit LimitConfig
+		}
+	}
+	loadTestConfig(t, &cfg, `
+[LimitLoad.Limit]
+BodyLimit = "1M"
+`)
+	require.Equal(t, LimitConfig{BodyLimit: "1M"}, cfg.LimitLoad.Limit)
+}
+
+func TestLimitedBody(t *testing.T) {
+	lb := &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("12345")), remaining: 5, limit: 5}
+	b, err := io.ReadAll(lb)
+	require.NoError(t, err)
+	require.Equal(t, "12345", string(b))
+	require.False(t, lb.exceeded)
+
+	lb = &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("123456")), remaining: 5, limit: 5}
+	b, err = io.ReadAll(lb)
+	var mbe *http.MaxBytesError
+	require.ErrorAs(t, err, &mbe)
+	require.Equal(t, "12345", string(b))
+	require.True(t, lb.exceeded)
+}
+
+func TestBodyLimit(t *testing.T) {
+	agw := newTestApiGateway(t)
+	require.NoError(t, agw.SetLimitConfig(&LimitConfig{BodyLimit: "16B"}))
+	require.NoError(t, agw.SetGroupConfigs(&GroupConfig{Prefix: "/upload", BodyLimit: "1K"}))
+	agw.configEcho()
+
+	called := false
+	readAll := func(c echo.Context) error {
+		called = true
+		b, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return SendResp(c, SuccessResp(len(b)))
+	}
+	swallow := func(c echo.Context) error {
+		_, _ = io.ReadAll(c.Request().Body)
+		return nil
+	}
+	agw.POST("/echo", readAll)
+	agw.POST("/swallow", swallow)
+	agw.POST("/upload", readAll)
+	agw.POST("/tiny", readAll, BodyLimit("4B"))
+
+	body := strings.Repeat("x", 32)
+
+	// rejected by Content-Length before reading
+	rec := serveTestRequest(agw, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(body)))
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	require.False(t, called)
+
+	// limited while reading the body of unknown length
+	req := httptest.NewRequest(http.MethodPost, "/echo", io.NopCloser(strings.NewReader(body)))
+	req.ContentLength = -1
+	rec = serveTestRequest(agw, req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	require.True(t, called)
+
+	req = httptest.NewRequest(http.MethodPost, "/swallow", io.NopCloser(strings.NewReader(body)))
+	req.ContentLength = -1
+	rec = serveTestRequest(agw, req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	// overridden by group
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"Result":32`)
+
+	// lowered by route
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodPost, "/tiny", strings.NewReader("12345")))
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodPost, "/tiny", strings.NewReader("1234")))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestTimeout(t *testing.T) {
+	agw := newTestApiGateway(t)
+	require.NoError(t, agw.SetGroupConfigs(&GroupConfig{Prefix: "/slow", Timeout: 20 * time.Millisecond}))
+	agw.configEcho()
+
+	agw.GET("/slow", func(c echo.Context) error {
+		<-c.Request().Context().Done()
+		return c.Request().Context().Err()
+	})
+	agw.GET("/silent", func(c echo.Context) error {
+		<-c.Request().Context().Done()
+		return nil
+	}, Timeout(20*time.Millisecond))
+	agw.GET("/fast", func(c echo.Context) error {
+		return SendResp(c, SuccessResp(nil))
+	}, Timeout(time.Second))
+
+	rec := serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	require.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	require.Contains(t, rec.Body.String(), "request timeout after 20ms")
+
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/silent", nil))
+	require.Equal(t, http.StatusGatewayTimeout, rec.Code)
+
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestTimeoutIgnoredContext(t *testing.T) {
+	agw := newTestApiGateway(t)
+	require.NoError(t, agw.SetLimitConfig(&LimitConfig{Timeout: 50 * time.Millisecond}))
+	agw.configEcho()
+
+	finished := make(chan struct{})
+	agw.GET("/stuck", func(c echo.Context) error {
+		defer close(finished)
+		time.Sleep(500 * time.Millisecond)
+		return SendResp(c, SuccessResp("late"))
+	})
+	agw.GET("/fast", func(c echo.Context) error {
+		c.Response().Header().Set("X-Fast", "1")
+		return SendResp(c, SuccessResp("fast"))
+	})
+	url := startTestServer(t, agw)
+
+	start := time.Now()
+	resp, err := http.Get(url + "/stuck")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Less(t, time.Since(start), 400*time.Millisecond)
+	require.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	require.Contains(t, string(body), "request timeout after 50ms")
+	require.NotContains(t, string(body), "late")
+	<-finished
+
+	resp, err = http.Get(url + "/fast")
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "1", resp.Header.Get("X-Fast"))
+	require.Contains(t, string(body), `"Result":"fast"`)
+}
+
+func TestInvalidBodyLimit(t *testing.T) {
+	agw := newTestApiGateway(t)
+	require.Error(t, agw.SetLimitConfig(&LimitConfig{BodyLimit: "16 apples"}))
+	err := agw.SetGroupConfigs(&GroupConfig{Prefix: "/upload", BodyLimit: "1Q"})
+	require.ErrorContains(t, err, "body limit of group /upload")
+}
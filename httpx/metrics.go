@@ -0,0 +1,119 @@
+package httpx
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/metricx"
+)
+
+const (
+	routeUnmatched = "<unmatched>"
+)
+
+// MetricsConfig enables request metrics of ApiGateway in Prometheus exposition format
+type MetricsConfig struct {
+	Enable bool
+	Path   string `vx_default:"/metrics"`
+	// Namespace is the prefix of metric names
+	Namespace string `vx_default:"httpx"`
+}
+
+type serverMetrics struct {
+	requests *metricx.CounterVec
+	latency  *metricx.HistogramVec
+	inFlight *metricx.GaugeVec
+	sizeIn   *metricx.HistogramVec
+	sizeOut  *metricx.HistogramVec
+}
+
+func newServerMetrics(reg *metricx.Registry, namespace string) *serverMetrics {
+	return &serverMetrics{
+		requests: reg.NewCounterVec(namespace+"_requests_total",
+			"Total number of HTTP requests.", "route", "method", "status"),
+		latency: reg.NewHistogramVec(namespace+"_request_duration_seconds",
+			"HTTP request latency in seconds.", metricx.DefBuckets, "route", "method"),
+		inFlight: reg.NewGaugeVec(namespace+"_requests_in_flight",
+			"Number of HTTP requests being served.", "route", "method"),
+		sizeIn: reg.NewHistogramVec(namespace+"_request_size_bytes",
+			"HTTP request body size in bytes.", metricx.SizeBuckets, "route", "method"),
+		sizeOut: reg.NewHistogramVec(namespace+"_response_size_bytes",
+			"HTTP response body size in bytes.", metricx.SizeBuckets, "route", "method"),
+	}
+}
+
+func (sm *serverMetrics) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			route := routeOf(c)
+
+			inFlight := sm.inFlight.With(route, req.Method)
+			inFlight.Inc()
+			start := time.Now()
+
+			err := next(c)
+
+			inFlight.Dec()
+			status := c.Response().Status
+			if err != nil && !c.Response().Committed {
+				status = Wrap(err).Status
+			}
+			sm.requests.With(route, req.Method, strconv.Itoa(status)).Inc()
+			sm.latency.With(route, req.Method).Observe(time.Since(start).Seconds())
+			sm.sizeIn.With(route, req.Method).Observe(float64(max(req.ContentLength, 0)))
+			sm.sizeOut.With(route, req.Method).Observe(float64(c.Response().Size))
+
+			return err
+		}
+	}
+}
+
+var (
+	notFoundHandlerPtr         = reflect.ValueOf(echo.NotFoundHandler).Pointer()
+	methodNotAllowedHandlerPtr = reflect.ValueOf(echo.MethodNotAllowedHandler).Pointer()
+)
+
+// routeOf returns the registered route template of the request instead of the raw path,
+// so that labels or keys built from it are bounded.
+func routeOf(c echo.Context) string {
+	if h := c.Handler(); h == nil || c.Path() == "" {
+		return routeUnmatched
+	} else if p := reflect.ValueOf(h).Pointer(); p == notFoundHandlerPtr || p == methodNotAllowedHandlerPtr {
+		// echo sets Path to the raw path if not found
+		return routeUnmatched
+	}
+	return c.Path()
+}
+
+// clientMetrics is shared by JsonClient registered into the same registry
+type clientMetrics struct {
+	requests *metricx.CounterVec
+	latency  *metricx.HistogramVec
+}
+
+func newClientMetrics(reg *metricx.Registry) *clientMetrics {
+	return &clientMetrics{
+		requests: reg.NewCounterVec("httpx_client_requests_total",
+			"Total number of requests sent by JsonClient.", "host", "method", "status"),
+		latency: reg.NewHistogramVec("httpx_client_request_duration_seconds",
+			"JsonClient request latency in seconds.", metricx.DefBuckets, "host", "method"),
+	}
+}
+
+func (cm *clientMetrics) observe(stats *RequestStats) {
+	host := stats.Host
+	if stats.Port != 0 {
+		host += ":" + strconv.Itoa(stats.Port)
+	}
+
+	status := "error"
+	if stats.Status != 0 {
+		status = strconv.Itoa(stats.Status)
+	}
+
+	cm.requests.With(host, stats.Method, status).Inc()
+	cm.latency.With(host, stats.Method).Observe(stats.RspTime.Sub(stats.ReqTime).Seconds())
+}
@@ -0,0 +1,34 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiGatewayMetrics(t *testing.T) {
+	agw := newTestApiGateway(t)
+	agw.SetMetricsConfig(&MetricsConfig{Enable: true, Path: "/metrics", Namespace: "test"})
+	agw.configEcho()
+	agw.GET("/v1/users/:id", func(c echo.Context) error {
+		return SendResp(c, SuccessResp(c.Param("id")))
+	})
+
+	for _, id := range []string{"1", "2"} {
+		rec := serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/v1/users/"+id, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	rec := serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/not_found", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	require.Contains(t, body, `test_requests_total{route="/v1/users/:id",method="GET",status="200"} 2`)
+	require.Contains(t, body, `test_requests_total{route="<unmatched>",method="GET",status="404"} 1`)
+	require.Contains(t, body, `test_request_duration_seconds_count{route="/v1/users/:id",method="GET"} 2`)
+	require.Contains(t, body, `test_requests_in_flight{route="/v1/users/:id",method="GET"} 0`)
+}
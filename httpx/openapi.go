@@ -0,0 +1,624 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/errcode_if"
+	"github.com/madlabx/pkgx/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	openAPIVersion = "3.0.3"
+
+	schemaRefPrefix    = "#/components/schemas/"
+	schemaJsonResponse = "JsonResponse"
+	schemaErrorCode    = "ErrorCode"
+)
+
+// OpenAPIConfig serves the OpenAPI 3 document of ApiGateway on Path+".json" and Path+".yaml"
+type OpenAPIConfig struct {
+	Enable      bool
+	Path        string `vx_default:"/openapi"`
+	Title       string
+	Version     string `vx_default:"1.0.0"`
+	Description string
+	// Servers are the base urls, e.g. https://api.example.com
+	Servers []string
+}
+
+// RouteDoc documents a route registered by ApiGateway.Document
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	// Request is the struct bound by BindAndValidate, documented by hx_tag as query,
+	// header parameters and body
+	Request any
+	// Response is the Result of JsonResponse on success
+	Response any
+	// Errors are the error responses of the route, e.g. errcode.ErrNotFound()
+	Errors []errcode_if.ErrorCodeIf
+}
+
+// Document attaches doc to route r, e.g.
+//
+//	agw.Document(agw.GET("/v1/users/:id", getUser), httpx.RouteDoc{Request: &GetUserReq{}, Response: &User{}})
+//
+// Request and Response are kept if absent in doc, e.g. documented by Handle.
+func (agw *ApiGateway) Document(r *echo.Route, doc RouteDoc) *echo.Route {
+	if agw.routeDocs == nil {
+		agw.routeDocs = make(map[string]*RouteDoc)
+	}
+	key := r.Method + " " + r.Path
+	if old, ok := agw.routeDocs[key]; ok {
+		if doc.Request == nil {
+			doc.Request = old.Request
+		}
+		if doc.Response == nil {
+			doc.Response = old.Response
+		}
+	}
+	agw.routeDocs[key] = &doc
+	return r
+}
+
+// SetOpenAPIConfig serves the OpenAPI document if oc.Enable
+func (agw *ApiGateway) SetOpenAPIConfig(oc *OpenAPIConfig) {
+	agw.openAPIConf = oc
+}
+
+// OpenAPIJSON generates the OpenAPI document of the registered routes
+func (agw *ApiGateway) OpenAPIJSON() ([]byte, error) {
+	return json.MarshalIndent(agw.openAPIDoc(), "", "  ")
+}
+
+// OpenAPIYAML generates the OpenAPI document of the registered routes in yaml
+func (agw *ApiGateway) OpenAPIYAML() ([]byte, error) {
+	raw, err := json.Marshal(agw.openAPIDoc())
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	// json is yaml, decoding into yaml.Node keeps the order of keys
+	var node yaml.Node
+	if err = yaml.Unmarshal(raw, &node); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return yaml.Marshal(&node)
+}
+
+func (agw *ApiGateway) openAPIHandlers() (jsonHandler, yamlHandler echo.HandlerFunc) {
+	jsonHandler = func(c echo.Context) error {
+		b, err := agw.OpenAPIJSON()
+		if err != nil {
+			return SendResp(c, InternalErrorResp(err))
+		}
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, b)
+	}
+	yamlHandler = func(c echo.Context) error {
+		b, err := agw.OpenAPIYAML()
+		if err != nil {
+			return SendResp(c, InternalErrorResp(err))
+		}
+		return c.Blob(http.StatusOK, "application/yaml; charset=UTF-8", b)
+	}
+	return
+}
+
+type openAPIDoc struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIServer struct {
+	Url string `json:"url"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	OperationId string                      `json:"operationId,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema   *openAPISchema             `json:"schema,omitempty"`
+	Examples map[string]*openAPIExample `json:"examples,omitempty"`
+}
+
+type openAPIExample struct {
+	Value any `json:"value"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	AllOf                []*openAPISchema          `json:"allOf,omitempty"`
+	Enum                 []any                     `json:"enum,omitempty"`
+	Default              any                       `json:"default,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+}
+
+func (agw *ApiGateway) openAPIDoc() *openAPIDoc {
+	conf := OpenAPIConfig{}
+	if agw.openAPIConf != nil {
+		conf = *agw.openAPIConf
+	}
+	if conf.Title == "" {
+		conf.Title = agw.name
+	}
+	if conf.Version == "" {
+		conf.Version = "1.0.0"
+	}
+
+	g := &openAPIGenerator{
+		schemas: make(map[string]*openAPISchema),
+		names:   make(map[reflect.Type]string),
+		dic:     agw.errCodeDictionary(),
+	}
+	doc := &openAPIDoc{
+		OpenAPI:    openAPIVersion,
+		Info:       openAPIInfo{Title: conf.Title, Version: conf.Version, Description: conf.Description},
+		Paths:      make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{Schemas: g.schemas},
+	}
+	for _, s := range conf.Servers {
+		doc.Servers = append(doc.Servers, openAPIServer{Url: s})
+	}
+
+	g.addEnvelope()
+
+	// sorted so that the suffixes of the duplicated operationIds are stable
+	routes := agw.Echo.Routes()
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Path+" "+routes[i].Method < routes[j].Path+" "+routes[j].Method
+	})
+	operationIds := make(map[string]int)
+	for _, r := range routes {
+		// skip the routes added by echo itself, e.g. by Group.Use
+		if strings.HasPrefix(r.Name, "github.com/labstack/echo.") {
+			continue
+		}
+		if agw.openAPIPaths[r.Path] {
+			continue
+		}
+
+		path, pathParams := openAPIPath(r.Path)
+		op := g.operation(r.Method, pathParams, agw.routeDocs[r.Method+" "+r.Path])
+		op.OperationId = openAPIOperationId(r.Method, r.Path)
+		if n := operationIds[op.OperationId]; n > 0 {
+			operationIds[op.OperationId] = n + 1
+			op.OperationId += strconv.Itoa(n + 1)
+		} else {
+			operationIds[op.OperationId] = 1
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*openAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(r.Method)] = op
+	}
+
+	return doc
+}
+
+// openAPIOperationId returns a camelCase id for the client generators, e.g. getV1UsersById
+// for GET /v1/users/:id
+func openAPIOperationId(method, p string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(p, "/") {
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			b.WriteString("By")
+			seg = seg[1:]
+		case seg == "*":
+			seg = "wildcard"
+		}
+		words := strings.FieldsFunc(seg, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, w := range words {
+			r, size := utf8.DecodeRuneInString(w)
+			b.WriteString(string(unicode.ToUpper(r)) + w[size:])
+		}
+	}
+	return b.String()
+}
+
+// openAPIPath converts echo path, e.g. /users/:id/*, to /users/{id}/{wildcard}
+func openAPIPath(p string) (string, []string) {
+	var params []string
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			params = append(params, seg[1:])
+			segs[i] = "{" + seg[1:] + "}"
+		case seg == "*":
+			params = append(params, "wildcard")
+			segs[i] = "{wildcard}"
+		}
+	}
+	return strings.Join(segs, "/"), params
+}
+
+type openAPIGenerator struct {
+	schemas map[string]*openAPISchema
+	names   map[reflect.Type]string
+	dic     errcode_if.ErrorCodeDictionaryIf
+}
+
+func (g *openAPIGenerator) addEnvelope() {
+	codeSchema := &openAPISchema{Type: "string"}
+	if lister, ok := g.dic.(errcode_if.ErrorCodeListerIf); ok {
+		codeSchema = &openAPISchema{Ref: schemaRefPrefix + schemaErrorCode}
+		ecSchema := &openAPISchema{Type: "string"}
+		var desc strings.Builder
+		for _, ec := range lister.ErrorCodes() {
+			ecSchema.Enum = append(ecSchema.Enum, ec.GetCode())
+			desc.WriteString("- " + ec.GetCode() + ": Errno " + strconv.Itoa(ec.GetErrno()) +
+				", HTTP status " + strconv.Itoa(ec.GetHttpStatus()) + "\n")
+		}
+		ecSchema.Description = desc.String()
+		g.schemas[schemaErrorCode] = ecSchema
+	}
+
+	g.schemas[schemaJsonResponse] = &openAPISchema{
+		Type: "object",
+		Properties: map[string]*openAPISchema{
+			"Code":      codeSchema,
+			"Errno":     {Type: "integer"},
+			"Message":   {Type: "string"},
+			"RequestId": {Type: "string"},
+			"Result":    {},
+		},
+	}
+}
+
+func (g *openAPIGenerator) operation(method string, pathParams []string, doc *RouteDoc) *openAPIOperation {
+	op := &openAPIOperation{Responses: make(map[string]*openAPIResponse)}
+	for _, p := range pathParams {
+		op.Parameters = append(op.Parameters, &openAPIParameter{
+			Name: p, In: "path", Required: true, Schema: &openAPISchema{Type: "string"},
+		})
+	}
+
+	success := &openAPIResponse{
+		Description: http.StatusText(http.StatusOK),
+		Content: map[string]*openAPIMediaType{
+			echo.MIMEApplicationJSON: {Schema: &openAPISchema{Ref: schemaRefPrefix + schemaJsonResponse}},
+		},
+	}
+	op.Responses[strconv.Itoa(http.StatusOK)] = success
+
+	if doc == nil {
+		return op
+	}
+
+	op.Summary = doc.Summary
+	op.Description = doc.Description
+	op.Tags = doc.Tags
+	op.Deprecated = doc.Deprecated
+
+	if doc.Request != nil {
+		hasBody := method != http.MethodGet && method != http.MethodHead && method != http.MethodDelete
+		body := &openAPISchema{Type: "object"}
+		g.requestFields(reflect.TypeOf(doc.Request), hasBody, body, op, map[reflect.Type]bool{})
+		if hasBody && len(body.Properties) > 0 {
+			op.RequestBody = &openAPIRequestBody{
+				Required: len(body.Required) > 0,
+				Content:  map[string]*openAPIMediaType{echo.MIMEApplicationJSON: {Schema: body}},
+			}
+		}
+	}
+
+	if doc.Response != nil {
+		success.Content[echo.MIMEApplicationJSON].Schema = &openAPISchema{AllOf: []*openAPISchema{
+			{Ref: schemaRefPrefix + schemaJsonResponse},
+			{Type: "object", Properties: map[string]*openAPISchema{"Result": g.schemaOf(reflect.TypeOf(doc.Response))}},
+		}}
+	}
+
+	for _, ec := range doc.Errors {
+		status := strconv.Itoa(ec.GetHttpStatus())
+		resp := op.Responses[status]
+		if resp == nil {
+			resp = &openAPIResponse{
+				Content: map[string]*openAPIMediaType{echo.MIMEApplicationJSON: {
+					Schema:   &openAPISchema{Ref: schemaRefPrefix + schemaJsonResponse},
+					Examples: make(map[string]*openAPIExample),
+				}},
+			}
+			op.Responses[status] = resp
+		}
+		if resp.Description != "" {
+			resp.Description += ", "
+		}
+		resp.Description += ec.GetCode()
+		resp.Content[echo.MIMEApplicationJSON].Examples[ec.GetCode()] = &openAPIExample{
+			Value: map[string]any{"Code": ec.GetCode(), "Errno": ec.GetErrno()},
+		}
+	}
+
+	return op
+}
+
+// requestFields follows BindAndValidate, body fields are named by field name and
+// query or header parameters by hx_name
+func (g *openAPIGenerator) requestFields(t reflect.Type, hasBody bool, body *openAPISchema,
+	op *openAPIOperation, visiting map[reflect.Type]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			g.requestFields(field.Type, hasBody, body, op, visiting)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr && ft.Elem().Kind() == reflect.Struct && ft.Elem() != timeType {
+			ft = ft.Elem()
+		}
+
+		switch {
+		case ft.Kind() == reflect.Struct && ft != timeType:
+			nested := &openAPISchema{Type: "object"}
+			g.requestFields(ft, hasBody, nested, op, visiting)
+			if len(nested.Properties) > 0 {
+				body.addProperty(field.Name, nested)
+			}
+			continue
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct && ft.Elem() != timeType:
+			nested := &openAPISchema{Type: "object"}
+			g.requestFields(ft.Elem(), hasBody, nested, op, visiting)
+			body.addProperty(field.Name, &openAPISchema{Type: "array", Items: nested})
+			continue
+		case ft.Kind() == reflect.Interface:
+			body.addProperty(field.Name, &openAPISchema{})
+			continue
+		}
+
+		ht, err := parseHxTag(field.Tag)
+		if err != nil {
+			continue
+		}
+
+		schema := g.schemaOf(field.Type)
+		applyHxTag(schema, ft, ht)
+
+		switch {
+		case ht.inHeader():
+			op.Parameters = append(op.Parameters, &openAPIParameter{
+				Name: ht.realName(field.Name), In: "header", Required: ht.must, Schema: schema,
+			})
+		case ht.place == constHxPlaceQuery || (ht.place == constHxPlaceEither && !hasBody):
+			op.Parameters = append(op.Parameters, &openAPIParameter{
+				Name: ht.realName(field.Name), In: "query", Required: ht.must, Schema: schema,
+			})
+		case hasBody:
+			body.addProperty(field.Name, schema)
+			if ht.must {
+				body.Required = append(body.Required, field.Name)
+			}
+		}
+	}
+}
+
+func (s *openAPISchema) addProperty(name string, ps *openAPISchema) {
+	if s.Properties == nil {
+		s.Properties = make(map[string]*openAPISchema)
+	}
+	s.Properties[name] = ps
+}
+
+// applyHxTag documents hx_default and hx_range in the same way as setFieldAndValidate
+func applyHxTag(s *openAPISchema, t reflect.Type, ht *hxTag) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+		if s.Items != nil {
+			s = s.Items
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if v, err := strconv.ParseFloat(ht.defaultValue, 64); err == nil {
+			s.Default = v
+		}
+		if rangeValues := strings.Split(ht.valueRange, "-"); len(rangeValues) == 2 {
+			minVal, e1 := strconv.ParseFloat(rangeValues[0], 64)
+			maxVal, e2 := strconv.ParseFloat(rangeValues[1], 64)
+			if e1 == nil && e2 == nil {
+				s.Minimum, s.Maximum = &minVal, &maxVal
+			}
+		}
+	case reflect.Bool:
+		if v, err := strconv.ParseBool(ht.defaultValue); err == nil {
+			s.Default = v
+		}
+	case reflect.String:
+		if ht.defaultValue != "" {
+			s.Default = ht.defaultValue
+		}
+		if ht.valueRange != "" {
+			for _, v := range strings.Split(ht.valueRange, ",") {
+				s.Enum = append(s.Enum, v)
+			}
+		}
+	default:
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf documents t as encoding/json does, named structs are added to components
+func (g *openAPIGenerator) schemaOf(t reflect.Type) *openAPISchema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var s *openAPISchema
+	switch t.Kind() {
+	case reflect.Bool:
+		s = &openAPISchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		s = &openAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		s = &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := float64(0)
+		s = &openAPISchema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		s = &openAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		s = &openAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		s = &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s = &openAPISchema{Type: "string", Format: "byte"}
+		} else {
+			s = &openAPISchema{Type: "array", Items: g.schemaOf(t.Elem())}
+		}
+	case reflect.Map:
+		s = &openAPISchema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			s = &openAPISchema{Type: "string", Format: "date-time"}
+		} else if t.Name() == "" {
+			s = g.structSchema(t)
+		} else {
+			s = &openAPISchema{Ref: schemaRefPrefix + g.structName(t)}
+		}
+	default:
+		s = &openAPISchema{}
+	}
+
+	if nullable && s.Ref == "" {
+		s.Nullable = true
+	}
+	return s
+}
+
+func (g *openAPIGenerator) structName(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, conflicted := g.schemas[name]; conflicted {
+		name = strings.ReplaceAll(t.String(), ".", "_")
+	}
+	g.names[t] = name
+	// placeholder for recursive types
+	g.schemas[name] = &openAPISchema{}
+	*g.schemas[name] = *g.structSchema(t)
+	return name
+}
+
+func (g *openAPIGenerator) structSchema(t reflect.Type) *openAPISchema {
+	s := &openAPISchema{Type: "object"}
+	g.jsonFields(t, s)
+	return s
+}
+
+func (g *openAPIGenerator) jsonFields(t reflect.Type, s *openAPISchema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.jsonFields(ft, s)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		s.addProperty(name, g.schemaOf(field.Type))
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+}
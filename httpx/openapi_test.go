@@ -0,0 +1,117 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/errcode_if"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type testListUsersReq struct {
+	Token  string `hx_place:"header" hx_name:"X-Token" hx_must:"true"`
+	Status string `hx_place:"query" hx_name:"status" hx_default:"active" hx_range:"active,deleted"`
+	Limit  int    `hx_tag:";limit;;20;1-100"`
+}
+
+type testCreateUserReq struct {
+	Name    string `hx_must:"true"`
+	Age     uint8  `hx_range:"0-150"`
+	Profile struct {
+		Email string `hx_must:"true"`
+	}
+}
+
+type testUser struct {
+	Id        string      `json:"id"`
+	Name      string      `json:"name"`
+	CreatedAt time.Time   `json:"created_at"`
+	Friends   []*testUser `json:"friends,omitempty"`
+}
+
+func TestOpenAPIOperationId(t *testing.T) {
+	require.Equal(t, "getV1UsersById", openAPIOperationId(http.MethodGet, "/v1/users/:id"))
+	require.Equal(t, "deleteFilesWildcard", openAPIOperationId(http.MethodDelete, "/files/*"))
+	require.Equal(t, "putUserProfilesByUserIdAvatar", openAPIOperationId(http.MethodPut, "/user-profiles/:user_id/avatar"))
+}
+
+func TestOpenAPI(t *testing.T) {
+	agw := newTestApiGateway(t)
+	agw.SetOpenAPIConfig(&OpenAPIConfig{Enable: true, Title: "users", Version: "1.2.0"})
+	agw.configEcho()
+	handler := func(c echo.Context) error { return SendResp(c, SuccessResp(nil)) }
+	agw.Document(agw.GET("/v1/users", handler), RouteDoc{
+		Summary:  "list users",
+		Request:  &testListUsersReq{},
+		Response: []testUser{},
+		Errors:   []errcode_if.ErrorCodeIf{newErrResp(http.StatusUnauthorized, "InvalidJwt"), newErrResp(http.StatusUnauthorized, "")},
+	})
+	agw.Document(agw.POST("/v1/users/:id", handler), RouteDoc{Request: &testCreateUserReq{}, Response: &testUser{}})
+	agw.DELETE("/v1/users/:id", handler)
+
+	rec := serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	require.Equal(t, "3.0.3", doc["openapi"])
+	require.Equal(t, map[string]any{"title": "users", "version": "1.2.0"}, doc["info"])
+
+	paths := doc["paths"].(map[string]any)
+	require.NotContains(t, paths, "/openapi.json")
+
+	list := paths["/v1/users"].(map[string]any)["get"].(map[string]any)
+	require.Equal(t, "list users", list["summary"])
+	require.Equal(t, "getV1Users", list["operationId"])
+	requireJSONEqual(t, `[
+		{"name":"X-Token","in":"header","required":true,"schema":{"type":"string"}},
+		{"name":"status","in":"query","schema":{"type":"string","enum":["active","deleted"],"default":"active"}},
+		{"name":"limit","in":"query","schema":{"type":"integer","format":"int64","default":20,"minimum":1,"maximum":100}}
+	]`, list["parameters"])
+	responses := list["responses"].(map[string]any)
+	requireJSONEqual(t, `{"allOf":[
+		{"$ref":"#/components/schemas/JsonResponse"},
+		{"type":"object","properties":{"Result":{"type":"array","items":{"$ref":"#/components/schemas/testUser"}}}}
+	]}`, responses["200"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"])
+	require.Equal(t, "InvalidJwt, Unauthorized", responses["401"].(map[string]any)["description"])
+
+	create := paths["/v1/users/{id}"].(map[string]any)["post"].(map[string]any)
+	require.Equal(t, "postV1UsersById", create["operationId"])
+	requireJSONEqual(t, `[{"name":"id","in":"path","required":true,"schema":{"type":"string"}}]`, create["parameters"])
+	requireJSONEqual(t, `{"required":true,"content":{"application/json":{"schema":{
+		"type":"object",
+		"properties":{
+			"Name":{"type":"string"},
+			"Age":{"type":"integer","minimum":0,"maximum":150},
+			"Profile":{"type":"object","properties":{"Email":{"type":"string"}},"required":["Email"]}
+		},
+		"required":["Name"]
+	}}}}`, create["requestBody"])
+	require.Contains(t, paths["/v1/users/{id}"], "delete")
+
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	requireJSONEqual(t, `{"type":"object","properties":{
+		"id":{"type":"string"},
+		"name":{"type":"string"},
+		"created_at":{"type":"string","format":"date-time"},
+		"friends":{"type":"array","items":{"$ref":"#/components/schemas/testUser"}}
+	},"required":["created_at","id","name"]}`, schemas["testUser"])
+	require.Contains(t, schemas, "JsonResponse")
+
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/openapi.yaml", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var yamlDoc map[string]any
+	require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &yamlDoc))
+	require.Equal(t, "3.0.3", yamlDoc["openapi"])
+}
+
+func requireJSONEqual(t *testing.T, expected string, actual any) {
+	b, err := json.Marshal(actual)
+	require.NoError(t, err)
+	require.JSONEq(t, expected, string(b))
+}
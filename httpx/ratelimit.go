@@ -0,0 +1,417 @@
+package httpx
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/madlabx/pkgx/errors"
+)
+
+const (
+	RateLimitTokenBucket   = "token_bucket"
+	RateLimitSlidingWindow = "sliding_window"
+
+	RateLimitKeyByIp     = "ip"
+	RateLimitKeyByRoute  = "route"
+	RateLimitKeyByHeader = "header:"
+
+	HeaderXRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderXRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderXRateLimitReset     = "X-RateLimit-Reset"
+	HeaderRetryAfter          = "Retry-After"
+
+	defaultRateLimitPeriod      = time.Second
+	defaultRateLimitIdleTimeout = 10 * time.Minute
+	defaultRateLimitMaxEntries  = 100000
+)
+
+// RateLimitConfig defines the config for RateLimit middleware.
+type RateLimitConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper `json:"-" mapstructure:"-"`
+
+	// Algorithm is token_bucket or sliding_window
+	Algorithm string `vx_default:"token_bucket"`
+	// Limit requests are allowed per Period for each key, 0 means no limit
+	Limit  int
+	Period time.Duration `vx_default:"1s"`
+	// Burst is the bucket capacity of token_bucket, Limit if 0
+	Burst int
+
+	// KeyBy is a comma separated list of
+	//
+	// - ip, the client ip from GetRealIp
+	// - route, the method and registered route of the request
+	// - header:<name>, e.g. header:X-Api-Key, falls back to ip if absent
+	//
+	// Ignored if KeyFunc is set.
+	KeyBy   string                      `vx_default:"ip"`
+	KeyFunc func(c echo.Context) string `json:"-" mapstructure:"-"`
+
+	// IdleTimeout evicts the limiters of keys idle for it
+	IdleTimeout time.Duration `vx_default:"10m"`
+	// MaxEntries caps the keys tracked, the least recently seen key is evicted when it is full,
+	// since the keys may be chosen by clients, e.g. by X-Forwarded-For
+	MaxEntries int `vx_default:"100000"`
+}
+
+// RateLimitResult is the decision of RateLimiter for one request
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the duration until the limit is fully restored
+	Reset time.Duration
+	// RetryAfter is the duration until the next request could be allowed, 0 if Allowed
+	RetryAfter time.Duration
+}
+
+// RateLimiter decides whether a request of key is allowed
+type RateLimiter interface {
+	Allow(key string) RateLimitResult
+}
+
+// NewRateLimiter returns an in-memory RateLimiter of config.Algorithm, the limiters
+// of idle keys are evicted after config.IdleTimeout, or once config.MaxEntries keys are tracked.
+func NewRateLimiter(config RateLimitConfig) (RateLimiter, error) {
+	if config.Limit <= 0 {
+		return nil, errors.Errorf("invalid rate limit:%v", config.Limit)
+	}
+	if config.Period <= 0 {
+		config.Period = defaultRateLimitPeriod
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.Limit
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaultRateLimitIdleTimeout
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = defaultRateLimitMaxEntries
+	}
+
+	var newState func() rateLimitState
+	switch config.Algorithm {
+	case "", RateLimitTokenBucket:
+		rate := float64(config.Limit) / config.Period.Seconds()
+		newState = func() rateLimitState {
+			return &tokenBucket{capacity: float64(config.Burst), rate: rate, tokens: float64(config.Burst)}
+		}
+	case RateLimitSlidingWindow:
+		newState = func() rateLimitState {
+			return &slidingWindow{limit: config.Limit, period: config.Period}
+		}
+	default:
+		return nil, errors.Errorf("unsupported rate limit algorithm:%v", config.Algorithm)
+	}
+
+	return &memoryRateLimiter{
+		newState:    newState,
+		idleTimeout: config.IdleTimeout,
+		maxEntries:  config.MaxEntries,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		now:         time.Now,
+	}, nil
+}
+
+type rateLimitState interface {
+	allow(now time.Time) RateLimitResult
+}
+
+type rateLimitEntry struct {
+	key      string
+	state    rateLimitState
+	lastSeen time.Time
+}
+
+type memoryRateLimiter struct {
+	mu          sync.Mutex
+	newState    func() rateLimitState
+	idleTimeout time.Duration
+	maxEntries  int
+	entries     map[string]*list.Element
+	// lru is ordered by lastSeen, the most recent at front
+	lru *list.List
+	now func() time.Time
+}
+
+func (ml *memoryRateLimiter) Allow(key string) RateLimitResult {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	now := ml.now()
+	ml.evict(now)
+
+	var entry *rateLimitEntry
+	if e, ok := ml.entries[key]; ok {
+		entry = e.Value.(*rateLimitEntry)
+		ml.lru.MoveToFront(e)
+	} else {
+		if ml.lru.Len() >= ml.maxEntries {
+			ml.remove(ml.lru.Back())
+		}
+		entry = &rateLimitEntry{key: key, state: ml.newState()}
+		ml.entries[key] = ml.lru.PushFront(entry)
+	}
+	entry.lastSeen = now
+
+	return entry.state.allow(now)
+}
+
+// evict removes the idle keys from the back of lru, so that no goroutine is needed
+func (ml *memoryRateLimiter) evict(now time.Time) {
+	for e := ml.lru.Back(); e != nil && now.Sub(e.Value.(*rateLimitEntry).lastSeen) >= ml.idleTimeout; e = ml.lru.Back() {
+		ml.remove(e)
+	}
+}
+
+func (ml *memoryRateLimiter) remove(e *list.Element) {
+	delete(ml.entries, e.Value.(*rateLimitEntry).key)
+	ml.lru.Remove(e)
+}
+
+func (ml *memoryRateLimiter) len() int {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	return len(ml.entries)
+}
+
+type tokenBucket struct {
+	capacity float64
+	// rate is the tokens refilled per second
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func (tb *tokenBucket) allow(now time.Time) RateLimitResult {
+	if !tb.last.IsZero() {
+		tb.tokens = math.Min(tb.capacity, tb.tokens+now.Sub(tb.last).Seconds()*tb.rate)
+	}
+	tb.last = now
+
+	res := RateLimitResult{Limit: int(tb.capacity)}
+	if tb.tokens >= 1 {
+		tb.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - tb.tokens) / tb.rate)
+	}
+	res.Remaining = int(tb.tokens)
+	res.Reset = secondsToDuration((tb.capacity - tb.tokens) / tb.rate)
+
+	return res
+}
+
+// slidingWindow approximates the requests in the last period by weighting the count
+// of the previous fixed window with its overlap.
+type slidingWindow struct {
+	limit   int
+	period  time.Duration
+	start   time.Time
+	prev    int
+	current int
+}
+
+func (sw *slidingWindow) allow(now time.Time) RateLimitResult {
+	if sw.start.IsZero() {
+		sw.start = now
+	}
+	if elapsed := now.Sub(sw.start); elapsed >= sw.period {
+		if elapsed >= 2*sw.period {
+			sw.prev = 0
+		} else {
+			sw.prev = sw.current
+		}
+		sw.current = 0
+		sw.start = sw.start.Add(elapsed / sw.period * sw.period)
+	}
+
+	elapsed := now.Sub(sw.start)
+	weight := 1 - float64(elapsed)/float64(sw.period)
+	estimated := float64(sw.prev)*weight + float64(sw.current)
+
+	res := RateLimitResult{Limit: sw.limit, Reset: sw.period - elapsed}
+	if estimated+1 <= float64(sw.limit) {
+		sw.current++
+		estimated++
+		res.Allowed = true
+	} else if sw.current+1 > sw.limit || sw.prev == 0 {
+		res.RetryAfter = sw.period - elapsed
+	} else {
+		// wait until the weighted previous window leaves room for one request
+		overlap := float64(sw.limit-sw.current-1) / float64(sw.prev)
+		res.RetryAfter = time.Duration((1-overlap)*float64(sw.period)) - elapsed
+	}
+	res.Remaining = max(sw.limit-int(math.Ceil(estimated)), 0)
+	if sw.current > 0 {
+		res.Reset += sw.period
+	}
+
+	return res
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// ceilSeconds formats d in seconds for headers, rounded up
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+func rateLimitKeyFunc(keyBy string) (func(c echo.Context) string, error) {
+	if keyBy == "" {
+		keyBy = RateLimitKeyByIp
+	}
+
+	var parts []func(c echo.Context) string
+	for _, by := range strings.Split(keyBy, ",") {
+		by = strings.TrimSpace(by)
+		switch {
+		case by == RateLimitKeyByIp:
+			parts = append(parts, func(c echo.Context) string { return GetRealIp(c.Request()) })
+		case by == RateLimitKeyByRoute:
+			parts = append(parts, func(c echo.Context) string { return c.Request().Method + " " + routeOf(c) })
+		case strings.HasPrefix(by, RateLimitKeyByHeader) && len(by) > len(RateLimitKeyByHeader):
+			name := by[len(RateLimitKeyByHeader):]
+			parts = append(parts, func(c echo.Context) string {
+				if v := c.Request().Header.Get(name); v != "" {
+					return v
+				}
+				return GetRealIp(c.Request())
+			})
+		default:
+			return nil, errors.Errorf("unsupported rate limit key:%v", by)
+		}
+	}
+
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	return func(c echo.Context) string {
+		keys := make([]string, len(parts))
+		for i, p := range parts {
+			keys[i] = p(c)
+		}
+		return strings.Join(keys, "|")
+	}, nil
+}
+
+// RateLimit returns a middleware which limits limit requests per period for each client ip.
+func RateLimit(limit int, period time.Duration) echo.MiddlewareFunc {
+	return RateLimitWithConfig(RateLimitConfig{Limit: limit, Period: period})
+}
+
+// RateLimitWithConfig returns a RateLimit middleware with config, requests over limit
+// are responded with http.StatusTooManyRequests, i.e. errcode.ErrTooManyRequests.
+// It panics if config is invalid.
+func RateLimitWithConfig(config RateLimitConfig) echo.MiddlewareFunc {
+	rl, err := newRateLimitPolicy(config)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if rl == nil {
+			return next
+		}
+		return func(c echo.Context) error {
+			return rl.handle(c, next)
+		}
+	}
+}
+
+type rateLimitPolicy struct {
+	skipper middleware.Skipper
+	keyFunc func(c echo.Context) string
+	limiter RateLimiter
+}
+
+// newRateLimitPolicy returns nil if config.Limit is 0
+func newRateLimitPolicy(config RateLimitConfig) (*rateLimitPolicy, error) {
+	if config.Limit == 0 {
+		return nil, nil
+	}
+
+	rl := &rateLimitPolicy{
+		skipper: config.Skipper,
+		keyFunc: config.KeyFunc,
+	}
+	if rl.skipper == nil {
+		rl.skipper = middleware.DefaultSkipper
+	}
+
+	var err error
+	if rl.keyFunc == nil {
+		if rl.keyFunc, err = rateLimitKeyFunc(config.KeyBy); err != nil {
+			return nil, err
+		}
+	}
+
+	if rl.limiter, err = NewRateLimiter(config); err != nil {
+		return nil, err
+	}
+
+	return rl, nil
+}
+
+func (rl *rateLimitPolicy) handle(c echo.Context, next echo.HandlerFunc) error {
+	if rl.skipper(c) {
+		return next(c)
+	}
+
+	res := rl.limiter.Allow(rl.keyFunc(c))
+
+	header := c.Response().Header()
+	header.Set(HeaderXRateLimitLimit, strconv.Itoa(res.Limit))
+	header.Set(HeaderXRateLimitRemaining, strconv.Itoa(res.Remaining))
+	header.Set(HeaderXRateLimitReset, ceilSeconds(res.Reset))
+
+	if !res.Allowed {
+		header.Set(HeaderRetryAfter, ceilSeconds(max(res.RetryAfter, time.Second)))
+		return SendResp(c, newErrResp(http.StatusTooManyRequests, ""))
+	}
+
+	return next(c)
+}
+
+type prefixedRateLimitPolicy struct {
+	prefix string
+	policy *rateLimitPolicy
+}
+
+func (agw *ApiGateway) rateLimitMiddleware() echo.MiddlewareFunc {
+	defPolicy := agw.rateLimitPolicy
+	groups := make([]prefixedRateLimitPolicy, 0, len(agw.groupRateLimit))
+	for prefix, p := range agw.groupRateLimit {
+		groups = append(groups, prefixedRateLimitPolicy{prefix: prefix, policy: p})
+	}
+	sort.Slice(groups, func(i, j int) bool { return len(groups[i].prefix) > len(groups[j].prefix) })
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p := defPolicy
+			for _, g := range groups {
+				if pathHasPrefix(c.Request().URL.Path, g.prefix) {
+					p = g.policy
+					break
+				}
+			}
+
+			if p == nil {
+				return next(c)
+			}
+			return p.handle(c, next)
+		}
+	}
+}
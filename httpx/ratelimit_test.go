@@ -0,0 +1,135 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRateLimiter(t *testing.T, config RateLimitConfig, now *time.Time) *memoryRateLimiter {
+	rl, err := NewRateLimiter(config)
+	require.NoError(t, err)
+	ml := rl.(*memoryRateLimiter)
+	ml.now = func() time.Time { return *now }
+	return ml
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rl := newTestRateLimiter(t, RateLimitConfig{Limit: 2, Period: time.Second, Burst: 3}, &now)
+
+	for i := 0; i < 3; i++ {
+		require.True(t, rl.Allow("a").Allowed)
+	}
+	res := rl.Allow("a")
+	require.False(t, res.Allowed)
+	require.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	require.True(t, rl.Allow("b").Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	res = rl.Allow("a")
+	require.True(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+	require.False(t, rl.Allow("a").Allowed)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rl := newTestRateLimiter(t, RateLimitConfig{Algorithm: RateLimitSlidingWindow, Limit: 4, Period: time.Second}, &now)
+
+	for i := 0; i < 4; i++ {
+		require.True(t, rl.Allow("a").Allowed)
+	}
+	res := rl.Allow("a")
+	require.False(t, res.Allowed)
+	require.Equal(t, time.Second, res.RetryAfter)
+
+	// 4 requests of previous window weigh 3 at 1/4 of the current window
+	now = now.Add(1250 * time.Millisecond)
+	require.True(t, rl.Allow("a").Allowed)
+	res = rl.Allow("a")
+	require.False(t, res.Allowed)
+	require.Equal(t, 250*time.Millisecond, res.RetryAfter)
+
+	now = now.Add(250 * time.Millisecond)
+	require.True(t, rl.Allow("a").Allowed)
+}
+
+func TestRateLimiterEvict(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rl := newTestRateLimiter(t, RateLimitConfig{Limit: 1, IdleTimeout: time.Minute}, &now)
+
+	rl.Allow("a")
+	now = now.Add(30 * time.Second)
+	rl.Allow("b")
+	require.Equal(t, 2, rl.len())
+
+	now = now.Add(40 * time.Second)
+	rl.Allow("c")
+	require.Equal(t, 2, rl.len())
+	// a was evicted, so it is allowed again
+	require.True(t, rl.Allow("a").Allowed)
+}
+
+func TestRateLimiterMaxEntries(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rl := newTestRateLimiter(t, RateLimitConfig{Limit: 1, MaxEntries: 2}, &now)
+
+	require.True(t, rl.Allow("a").Allowed)
+	require.True(t, rl.Allow("b").Allowed)
+	require.False(t, rl.Allow("a").Allowed)
+	// b is the least recently seen
+	require.True(t, rl.Allow("c").Allowed)
+	require.Equal(t, 2, rl.len())
+	require.False(t, rl.Allow("a").Allowed)
+	require.True(t, rl.Allow("b").Allowed)
+}
+
+func TestApiGatewayRateLimit(t *testing.T) {
+	agw := newTestApiGateway(t)
+	require.NoError(t, agw.SetRateLimitConfig(&RateLimitConfig{Limit: 1, Period: time.Minute}))
+	require.NoError(t, agw.SetGroupRateLimitConfig("/internal", &RateLimitConfig{}))
+	require.NoError(t, agw.SetGroupRateLimitConfig("/v1/keys", &RateLimitConfig{Limit: 2, Period: time.Minute, KeyBy: "header:X-Api-Key"}))
+	agw.configEcho()
+	handler := func(c echo.Context) error { return SendResp(c, SuccessResp(nil)) }
+	agw.GET("/v1/users", handler)
+	agw.GET("/v1/keys", handler)
+	agw.GET("/internal/stats", handler)
+
+	rec := serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "1", rec.Header().Get(HeaderXRateLimitLimit))
+	require.Equal(t, "0", rec.Header().Get(HeaderXRateLimitRemaining))
+	require.Equal(t, "60", rec.Header().Get(HeaderXRateLimitReset))
+
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/v1/users", nil))
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "60", rec.Header().Get(HeaderRetryAfter))
+	require.Contains(t, rec.Body.String(), `"Code":"TooManyRequests"`)
+
+	for i := 0; i < 3; i++ {
+		rec = serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/internal/stats", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Empty(t, rec.Header().Get(HeaderXRateLimitLimit))
+	}
+
+	for _, key := range []string{"k1", "k1", "k2"} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/keys", nil)
+		req.Header.Set("X-Api-Key", key)
+		require.Equal(t, http.StatusOK, serveTestRequest(agw, req).Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v1/keys", nil)
+	req.Header.Set("X-Api-Key", "k1")
+	require.Equal(t, http.StatusTooManyRequests, serveTestRequest(agw, req).Code)
+}
+
+func TestApiGatewayInvalidRateLimit(t *testing.T) {
+	agw := newTestApiGateway(t)
+	require.Error(t, agw.SetRateLimitConfig(&RateLimitConfig{Limit: 1, Algorithm: "leaky_bucket"}))
+	err := agw.SetGroupConfigs(&GroupConfig{Prefix: "/v1", RateLimit: &RateLimitConfig{Limit: 1, KeyBy: "cookie:sid"}})
+	require.ErrorContains(t, err, "rate limit of group /v1")
+}
@@ -0,0 +1,67 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/madlabx/pkgx/errors"
+	"github.com/madlabx/pkgx/log"
+)
+
+// RecoverConfig defines the config for Recover middleware.
+type RecoverConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// Logger prints the panic with its stack.
+	// Optional. Default value log.StandardLogger().
+	Logger *log.Logger
+}
+
+// Recover returns a middleware which recovers from panics in the chain, logs the stack and
+// responds errCodeDic.GetInternalError() through SendResp.
+func Recover() echo.MiddlewareFunc {
+	return RecoverWithConfig(RecoverConfig{})
+}
+
+func RecoverWithConfig(config RecoverConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+
+	if config.Logger == nil {
+		config.Logger = log.StandardLogger()
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+
+				// http.ErrAbortHandler is used to abort the response on purpose
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+
+				// do not use %w, keep the stack of panic instead of the one of panic value
+				perr := errors.Wrap(fmt.Errorf("panic: %v", r))
+				req := c.Request()
+				config.Logger.WithContext(req.Context()).Errorf("Recovered from %s %s, %+v",
+					req.Method, req.RequestURI, perr)
+
+				err = SendResp(c, InternalErrorResp(perr))
+			}()
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover(t *testing.T) {
+	agw := newTestApiGateway(t)
+	var logBuf bytes.Buffer
+	agw.Logger.SetOutput(&logBuf)
+	agw.configEcho()
+
+	agw.GET("/v1/panic", func(c echo.Context) error {
+		panic("something wrong")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/panic", nil)
+	req.Header.Set(echo.HeaderXRequestID, "panic-id")
+	rec := serveTestRequest(agw, req)
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	jr := &JsonResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), jr))
+	require.Equal(t, TrimHttpStatusText(http.StatusInternalServerError), jr.Code)
+	require.Equal(t, http.StatusInternalServerError, jr.Errno)
+	require.Equal(t, "panic-id", jr.RequestId)
+	require.NotContains(t, jr.Message, "something wrong")
+
+	require.Contains(t, logBuf.String(), "panic: something wrong")
+	require.Contains(t, logBuf.String(), "recover_test.go")
+}
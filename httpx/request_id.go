@@ -0,0 +1,84 @@
+package httpx
+
+import (
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/madlabx/pkgx/log"
+)
+
+const (
+	ctxKeyRequestId = "httpx.request_id"
+
+	maxRequestIdLength = 128
+)
+
+// RequestIdConfig defines the config for RequestId middleware.
+type RequestIdConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+
+	// Generator mints the id if the client sent no valid X-Request-ID.
+	// Optional. Default value NewRequestId of ErrCodeDictionary(c).
+	Generator func() string
+}
+
+// RequestId accepts X-Request-ID from the client or generates one, then stores it in the
+// request context so that SendResp, the access logger, log.WithContext and JsonClient
+// share the same id.
+func RequestId() echo.MiddlewareFunc {
+	return RequestIdWithConfig(RequestIdConfig{})
+}
+
+func RequestIdWithConfig(config RequestIdConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+			rid := req.Header.Get(echo.HeaderXRequestID)
+			if !isValidRequestId(rid) {
+				if config.Generator != nil {
+					rid = config.Generator()
+				} else {
+					rid = ErrCodeDictionary(c).NewRequestId()
+				}
+			}
+
+			c.Set(ctxKeyRequestId, rid)
+			c.SetRequest(req.WithContext(log.ContextWithRequestId(req.Context(), rid)))
+			c.Response().Header().Set(echo.HeaderXRequestID, rid)
+
+			return next(c)
+		}
+	}
+}
+
+// isValidRequestId only accepts printable ASCII to avoid header or log injection
+func isValidRequestId(rid string) bool {
+	if rid == "" || len(rid) > maxRequestIdLength {
+		return false
+	}
+
+	for i := 0; i < len(rid); i++ {
+		if rid[i] <= ' ' || rid[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetRequestId returns the id set by RequestId middleware, "" if not set
+func GetRequestId(c echo.Context) string {
+	if rid, ok := c.Get(ctxKeyRequestId).(string); ok {
+		return rid
+	}
+
+	return log.RequestIdFromContext(c.Request().Context())
+}
@@ -0,0 +1,53 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/log"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIdPropagation(t *testing.T) {
+	agw := newTestApiGateway(t)
+	agw.configEcho()
+
+	var logBuf bytes.Buffer
+	lg := log.New()
+	lg.SetOutput(&logBuf)
+	lg.SetFormatter(&logrus.JSONFormatter{})
+
+	agw.GET("/v1/echo", func(c echo.Context) error {
+		lg.WithContext(c.Request().Context()).Info("in handler")
+		return SendResp(c, SuccessResp("ok"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/echo", nil)
+	req.Header.Set(echo.HeaderXRequestID, "client-id-1")
+	rec := serveTestRequest(agw, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "client-id-1", rec.Header().Get(echo.HeaderXRequestID))
+
+	jr := &JsonResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), jr))
+	require.Equal(t, "client-id-1", jr.RequestId)
+	require.Contains(t, logBuf.String(), `"request_id":"client-id-1"`)
+
+	// invalid id from client is replaced
+	req = httptest.NewRequest(http.MethodGet, "/v1/echo", nil)
+	req.Header.Set(echo.HeaderXRequestID, "bad id\n"+strings.Repeat("x", 10))
+	rec = serveTestRequest(agw, req)
+	rid := rec.Header().Get(echo.HeaderXRequestID)
+	require.NotEmpty(t, rid)
+	require.NotContains(t, rid, " ")
+
+	jr = &JsonResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), jr))
+	require.Equal(t, rid, jr.RequestId)
+}
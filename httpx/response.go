@@ -1,6 +1,9 @@
 package httpx
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -12,17 +15,35 @@ import (
 	"github.com/madlabx/pkgx/utils"
 )
 
+// errCodeDic is the package dictionary registered by RegisterErrCodeDictionary, which may be
+// overridden per ApiGateway by SetErrCodeDictionary
 var errCodeDic errcode_if.ErrorCodeDictionaryIf
 
+type errCodeDicKey struct{}
+
 func init() {
 	errCodeDic = &errcode_if.DefaultErrCodeDic{}
 }
 
+// errCodeKind marks the JsonResponse built from the dictionary, so that SendResp resolves it
+// again by the dictionary of the ApiGateway serving the request
+type errCodeKind int
+
+const (
+	errCodeKindNone errCodeKind = iota
+	errCodeKindSuccess
+	errCodeKindBadRequest
+	errCodeKindInternalError
+	// errCodeKindStatus is the code of ToCode(Status)
+	errCodeKindStatus
+)
+
 var _ errcode_if.ErrorCodeIf = &JsonResponse{}
 
 // JsonResponse should be:
 type JsonResponse struct {
-	err error
+	err  error
+	kind errCodeKind
 
 	Status int `json:"-"`
 
@@ -131,7 +152,7 @@ func (jr *JsonResponse) Format(s fmt.State, verb rune) {
 }
 
 // WithMessagef set Message, won't impact IsOK()
-// in jr.cjson, if Message is not "", won't return err.Error
+// in jr.writeJSON, if Message is not "", won't return err.Error
 func (jr *JsonResponse) WithMsgf(format string, a ...any) *JsonResponse {
 	jr.Message = fmt.Sprintf(format, a...)
 	return jr
@@ -142,7 +163,7 @@ func (jr *JsonResponse) WithMessagef(format string, a ...any) error {
 }
 
 // WithError if has err, join with Error
-// in jr.cjson, if Message is nil, use err.Error
+// in jr.writeJSON, if Message is nil, use err.Error
 func (jr *JsonResponse) WithError(err error, depths ...int) *JsonResponse {
 	if err == nil {
 		return jr
@@ -170,7 +191,7 @@ func (jr *JsonResponse) WithError(err error, depths ...int) *JsonResponse {
 }
 
 // WithErrorf set err
-// in jr.cjson, if Message is nil, use err.Error
+// in jr.writeJSON, if Message is nil, use err.Error
 func (jr *JsonResponse) WithErrorf(format string, a ...any) *JsonResponse {
 	if format == "" {
 		return jr
@@ -198,16 +219,25 @@ func (jr *JsonResponse) Copy() *JsonResponse {
 	return &obj
 }
 
-func (jr *JsonResponse) cjson(c echo.Context) error {
+// writeJSON responds jr as echo.Context.JSON does, indented if pretty. The body is encoded before
+// writing, so that nothing is written if jr could not be encoded.
+func (jr *JsonResponse) writeJSON(w http.ResponseWriter, pretty bool) error {
 	if jr.Code == "" && jr.Errno == 0 && jr.Result == nil {
-		return c.NoContent(jr.Status)
+		w.WriteHeader(jr.Status)
+		return nil
 	}
 
-	err := c.JSON(jr.Status, jr.CompleteMessage())
-	if err != nil {
-		err = jr.Unwrap()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	if pretty {
+		enc.SetIndent("", "  ")
 	}
-
+	if err := enc.Encode(jr.CompleteMessage()); err != nil {
+		return errors.Wrapf(err, "failed to encode response")
+	}
+	w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+	w.WriteHeader(jr.Status)
+	_, err := w.Write(buf.Bytes())
 	return err
 }
 
@@ -258,7 +288,26 @@ func (jr *JsonResponse) Unwrap() error {
 
 func (jr *JsonResponse) IsOK() bool {
 	//jr.Status is not reliable
-	return jr.Code == errCodeDic.GetSuccess().GetCode()
+	return jr.kind == errCodeKindSuccess || jr.Code == errCodeDic.GetSuccess().GetCode()
+}
+
+// resolve replaces the codes built from the package dictionary with the ones of dic
+func (jr *JsonResponse) resolve(dic errcode_if.ErrorCodeDictionaryIf) {
+	var ec errcode_if.ErrorCodeIf
+	switch jr.kind {
+	case errCodeKindSuccess:
+		ec = dic.GetSuccess()
+	case errCodeKindBadRequest:
+		ec = dic.GetBadRequest()
+	case errCodeKindInternalError:
+		ec = dic.GetInternalError()
+	case errCodeKindStatus:
+		jr.Code = dic.ToCode(jr.Status)
+		return
+	default:
+		return
+	}
+	jr.Status, jr.Code, jr.Errno = ec.GetHttpStatus(), ec.GetCode(), ec.GetErrno()
 }
 
 // Cause return children err. will not recursively retrieve err.Cause
@@ -326,10 +375,33 @@ func Wrap(err error) *JsonResponse {
 	return jr
 }
 
+// RegisterErrCodeDictionary sets the package dictionary used by the ApiGateways without
+// SetErrCodeDictionary
 func RegisterErrCodeDictionary(dic errcode_if.ErrorCodeDictionaryIf) {
 	errCodeDic = dic
 }
 
+// ContextWithErrCodeDictionary returns a copy of ctx carrying dic, which overrides the package
+// dictionary for SendResp and WriteResp, e.g. in the middleware of plain http.Handler
+func ContextWithErrCodeDictionary(ctx context.Context, dic errcode_if.ErrorCodeDictionaryIf) context.Context {
+	return context.WithValue(ctx, errCodeDicKey{}, dic)
+}
+
+// ErrCodeDictionaryFromContext returns the dictionary carried by ctx, or the package dictionary
+func ErrCodeDictionaryFromContext(ctx context.Context) errcode_if.ErrorCodeDictionaryIf {
+	if ctx != nil {
+		if dic, ok := ctx.Value(errCodeDicKey{}).(errcode_if.ErrorCodeDictionaryIf); ok {
+			return dic
+		}
+	}
+	return errCodeDic
+}
+
+// ErrCodeDictionary returns the dictionary of the ApiGateway serving c, or the package dictionary
+func ErrCodeDictionary(c echo.Context) errcode_if.ErrorCodeDictionaryIf {
+	return ErrCodeDictionaryFromContext(c.Request().Context())
+}
+
 func SuccessResp(result any) *JsonResponse {
 	ec := errCodeDic.GetSuccess()
 	return &JsonResponse{
@@ -337,9 +409,52 @@ func SuccessResp(result any) *JsonResponse {
 		Errno:  ec.GetErrno(),
 		Code:   ec.GetCode(),
 		Result: result,
+		kind:   errCodeKindSuccess,
 	}
 }
 
+// newErrResp builds the same JsonResponse as errcode.New(status, code)() does, since httpx cannot
+// import errcode which depends on httpx. code defaults to errCodeDic.ToCode(status).
+func newErrResp(status int, code string) *JsonResponse {
+	kind := errCodeKindNone
+	if code == "" {
+		code = errCodeDic.ToCode(status)
+		kind = errCodeKindStatus
+	}
+	return &JsonResponse{
+		Status: status,
+		Code:   code,
+		Errno:  status,
+		kind:   kind,
+	}
+}
+
+// InternalErrorResp wraps err with errCodeDic.GetInternalError(), e.g. errcode.ErrInternalServerError.
+// err is kept for logging while clients only get the status text as Message.
+func InternalErrorResp(err error) *JsonResponse {
+	ec := errCodeDic.GetInternalError()
+	jr := (&JsonResponse{
+		Status: ec.GetHttpStatus(),
+		Code:   ec.GetCode(),
+		Errno:  ec.GetErrno(),
+		kind:   errCodeKindInternalError,
+	}).WithError(err, 2)
+	jr.Message = http.StatusText(jr.Status)
+	return jr
+}
+
+// BadRequestResp wraps err with errCodeDic.GetBadRequest(), e.g. errcode.ErrBadRequest,
+// err is responded as Message since it is caused by the client, e.g. by BindAndValidate.
+func BadRequestResp(err error) *JsonResponse {
+	ec := errCodeDic.GetBadRequest()
+	return (&JsonResponse{
+		Status: ec.GetHttpStatus(),
+		Code:   ec.GetCode(),
+		Errno:  ec.GetErrno(),
+		kind:   errCodeKindBadRequest,
+	}).WithError(err, 2)
+}
+
 //func ResultResp(status int, code errcodex.ErrorCodeIf, result any) *JsonResponse {
 //	return &JsonResponse{
 //		Status: status,
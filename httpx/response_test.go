@@ -1,10 +1,14 @@
 package httpx
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/labstack/echo"
 	"github.com/madlabx/pkgx/errors"
 	"github.com/stretchr/testify/require"
 )
@@ -90,3 +94,80 @@ func TestRawStruct(t *testing.T) {
 	require.Equal(t, errString, jr.Cause())
 	require.Equal(t, resultString, jr.Result)
 }
+
+func TestNetHttpAdapters(t *testing.T) {
+	type createReq struct {
+		Name   string `hx_place:"body" hx_must:"true" hx_range:"alice,bob"`
+		Region string `hx_place:"query" hx_name:"region" hx_default:"cn"`
+		Token  string `hx_place:"header" hx_name:"X-Token"`
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/users", func(w http.ResponseWriter, r *http.Request) {
+		var req createReq
+		if err := BindAndValidateRequest(r, &req); err != nil {
+			_ = WriteResp(w, r, BadRequestResp(err))
+			return
+		}
+		_ = WriteResp(w, r, SuccessResp(req))
+	})
+	dicMux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(ContextWithErrCodeDictionary(r.Context(), &testErrCodeDic{prefix: "mux"}))
+		mux.ServeHTTP(w, r)
+	})
+
+	serve := func(h http.Handler, body string) (*httptest.ResponseRecorder, map[string]any) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/users?region=us", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-Token", "t1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		var m map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m), rec.Body.String())
+		return rec, m
+	}
+
+	rec, resp := serve(mux, `{"Name":"alice"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+	require.NotEmpty(t, rec.Header().Get(echo.HeaderXRequestID))
+	require.Equal(t, rec.Header().Get(echo.HeaderXRequestID), resp["RequestId"])
+	require.Equal(t, "OK", resp["Code"])
+	require.Equal(t, map[string]any{"Name": "alice", "Region": "us", "Token": "t1"}, resp["Result"])
+
+	rec, resp = serve(mux, `{"Name":"eve"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, "BadRequest", resp["Code"])
+	require.NotEmpty(t, resp["Message"])
+
+	// the dictionary carried by the request context
+	rec, resp = serve(dicMux, `{"Name":"bob"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "muxSuccess", resp["Code"])
+	require.Equal(t, "mux-1", rec.Header().Get(echo.HeaderXRequestID))
+}
+
+func TestSendRespEncoding(t *testing.T) {
+	e := echo.New()
+	send := func(target string, resp error) (*httptest.ResponseRecorder, error) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec)
+		return rec, SendResp(c, resp)
+	}
+
+	rec, err := send("/", SuccessResp(map[string]string{"a": "b"}))
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(rec.Body.String(), `"Result":{"a":"b"}}`+"\n"), rec.Body.String())
+
+	e.Debug = true
+	rec, err = send("/", SuccessResp(map[string]string{"a": "b"}))
+	require.NoError(t, err)
+	require.Contains(t, rec.Body.String(), "\n  \"Result\": {\n    \"a\": \"b\"\n  }\n}\n")
+	e.Debug = false
+
+	// nothing is written if the result could not be encoded
+	rec = httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	require.Error(t, SendResp(c, SuccessResp(make(chan int))))
+	require.False(t, c.Response().Committed)
+	require.Empty(t, rec.Body.String())
+}
@@ -0,0 +1,348 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/madlabx/pkgx/errors"
+	"github.com/madlabx/pkgx/utils"
+)
+
+const (
+	SignAlgorithmMd5  = "md5"
+	SignAlgorithmSha1 = "sha1"
+
+	// codes of errcode.ErrWrongSign etc., httpx could not import errcode
+	codeWrongSign      = "WrongSign"
+	codeExpiredRequest = "ExpiredRequest"
+	codeInvalidNonce   = "InvalidNonce"
+	codeExpiredNonce   = "ExpiredNonce"
+
+	signAppIdContextKey = "httpx.sign_app_id"
+
+	defaultSignTimestampWindow = 5 * time.Minute
+	maxNonceLength             = 128
+)
+
+// SignKeyStore returns the secret of appId, e.g. from database or config center
+type SignKeyStore interface {
+	GetSecret(ctx context.Context, appId string) (string, error)
+}
+
+// SignKeyStoreFunc adapts a function to SignKeyStore
+type SignKeyStoreFunc func(ctx context.Context, appId string) (string, error)
+
+func (f SignKeyStoreFunc) GetSecret(ctx context.Context, appId string) (string, error) {
+	return f(ctx, appId)
+}
+
+// StaticSignKeyStore maps appId to secret
+type StaticSignKeyStore map[string]string
+
+func (s StaticSignKeyStore) GetSecret(_ context.Context, appId string) (string, error) {
+	secret, ok := s[appId]
+	if !ok {
+		return "", errors.Errorf("unknown app id:%v", appId)
+	}
+	return secret, nil
+}
+
+// NonceStore remembers the used nonces for ttl
+type NonceStore interface {
+	// CheckAndSet returns false if nonce was used within ttl, otherwise remembers it
+	CheckAndSet(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// SignConfig defines the config for Sign middleware.
+//
+// The client signs the request by
+//
+//	Sign = utils.Md5Sum(utils.GetSignStringOfMap(params) + "&Secret=" + secret)
+//
+// where params are the query and form or top level JSON body parameters, including
+// AppId, Timestamp and Nonce, but excluding Sign. SignParams does the same.
+type SignConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper `json:"-" mapstructure:"-"`
+
+	// KeyStore returns the secret of AppId, required
+	KeyStore SignKeyStore `json:"-" mapstructure:"-"`
+	// NonceStore rejects the replayed nonces
+	// Optional. Default value NewMemoryNonceStore().
+	NonceStore NonceStore `json:"-" mapstructure:"-"`
+
+	// Algorithm is md5 or sha1
+	Algorithm string `vx_default:"md5"`
+	// TimestampWindow is the max difference between Timestamp and now,
+	// the nonces are remembered for twice of it
+	TimestampWindow time.Duration `vx_default:"5m"`
+	// DisableNonce skips the nonce check, i.e. replays within TimestampWindow are allowed
+	DisableNonce bool
+
+	// names of parameters
+	AppIdKey     string `vx_default:"AppId"`
+	SignKey      string `vx_default:"Sign"`
+	TimestampKey string `vx_default:"Timestamp"`
+	NonceKey     string `vx_default:"Nonce"`
+}
+
+// SignParams returns the signature of params with secret, params["Sign"] is ignored
+func SignParams(params map[string]string, secret, algorithm string) string {
+	signString := utils.GetSignStringOfMap(params) + "&Secret=" + secret
+	if algorithm == SignAlgorithmSha1 {
+		return utils.Sha1Sum(signString)
+	}
+	return utils.Md5Sum(signString)
+}
+
+// Sign returns a Sign middleware verifying requests with the secrets in ks.
+func Sign(ks SignKeyStore) echo.MiddlewareFunc {
+	return SignWithConfig(SignConfig{KeyStore: ks})
+}
+
+// SignWithConfig returns a Sign middleware with config, the verified AppId is available
+// by GetSignAppId. Failures are responded with the JsonResponse of errcode.ErrWrongSign,
+// ErrExpiredRequest, ErrInvalidNonce or ErrExpiredNonce.
+// It panics if there is no KeyStore.
+func SignWithConfig(config SignConfig) echo.MiddlewareFunc {
+	if config.KeyStore == nil {
+		panic("sign middleware requires key store")
+	}
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.NonceStore == nil && !config.DisableNonce {
+		config.NonceStore = NewMemoryNonceStore()
+	}
+	if config.Algorithm == "" {
+		config.Algorithm = SignAlgorithmMd5
+	}
+	if config.Algorithm != SignAlgorithmMd5 && config.Algorithm != SignAlgorithmSha1 {
+		panic("unsupported sign algorithm:" + config.Algorithm)
+	}
+	if config.TimestampWindow <= 0 {
+		config.TimestampWindow = defaultSignTimestampWindow
+	}
+	if config.AppIdKey == "" {
+		config.AppIdKey = "AppId"
+	}
+	if config.SignKey == "" {
+		config.SignKey = "Sign"
+	}
+	if config.TimestampKey == "" {
+		config.TimestampKey = "Timestamp"
+	}
+	if config.NonceKey == "" {
+		config.NonceKey = "Nonce"
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			if code, err := verifySign(c, &config); err != nil {
+				return SendResp(c, newErrResp(httpStatusOfSignCode(code), code).WithError(err))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func httpStatusOfSignCode(code string) int {
+	switch code {
+	case codeInvalidNonce, codeExpiredNonce:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func verifySign(c echo.Context, config *SignConfig) (string, error) {
+	params, err := signParamsOf(c.Request())
+	if err != nil {
+		return codeWrongSign, err
+	}
+
+	sign := params[config.SignKey]
+	appId := params[config.AppIdKey]
+	if sign == "" || appId == "" {
+		return codeWrongSign, errors.Errorf("missing %v or %v", config.SignKey, config.AppIdKey)
+	}
+	delete(params, config.SignKey)
+
+	ts, err := parseSignTimestamp(params[config.TimestampKey])
+	if err != nil {
+		return codeExpiredRequest, errors.Wrapf(err, "invalid %v", config.TimestampKey)
+	}
+	if diff := time.Since(ts); diff > config.TimestampWindow || diff < -config.TimestampWindow {
+		return codeExpiredRequest, errors.Errorf("timestamp out of window:%v", ts.Format(time.RFC3339))
+	}
+
+	nonce := params[config.NonceKey]
+	if !config.DisableNonce && (nonce == "" || len(nonce) > maxNonceLength) {
+		return codeInvalidNonce, errors.Errorf("invalid %v", config.NonceKey)
+	}
+
+	ctx := c.Request().Context()
+	secret, err := config.KeyStore.GetSecret(ctx, appId)
+	if err != nil {
+		return codeWrongSign, err
+	}
+
+	expected := SignParams(params, secret, config.Algorithm)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(sign))) != 1 {
+		return codeWrongSign, errors.New("signature mismatch")
+	}
+
+	// remember nonce after the signature is verified, so that nonces could not be burnt by others
+	if !config.DisableNonce {
+		ok, err := config.NonceStore.CheckAndSet(ctx, appId+":"+nonce, 2*config.TimestampWindow)
+		if err != nil {
+			return codeInvalidNonce, err
+		}
+		if !ok {
+			return codeExpiredNonce, errors.Errorf("nonce already used:%v", nonce)
+		}
+	}
+
+	c.Set(signAppIdContextKey, appId)
+	return "", nil
+}
+
+// GetSignAppId returns the AppId verified by Sign middleware
+func GetSignAppId(c echo.Context) string {
+	appId, _ := c.Get(signAppIdContextKey).(string)
+	return appId
+}
+
+// parseSignTimestamp accepts unix timestamp in seconds or milliseconds
+func parseSignTimestamp(s string) (time.Time, error) {
+	ts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ts > 1e12 {
+		return time.UnixMilli(ts), nil
+	}
+	return time.Unix(ts, 0), nil
+}
+
+// signParamsOf collects query and body parameters, the body is restored for the handler
+func signParamsOf(req *http.Request) (map[string]string, error) {
+	params := make(map[string]string)
+	for k, vs := range req.URL.Query() {
+		if len(vs) > 0 {
+			params[k] = vs[0]
+		}
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return params, nil
+	}
+
+	ctype := req.Header.Get(echo.HeaderContentType)
+	isForm := strings.HasPrefix(ctype, echo.MIMEApplicationForm)
+	isJson := strings.HasPrefix(ctype, echo.MIMEApplicationJSON)
+	if !isForm && !isJson {
+		return params, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) == 0 {
+		return params, nil
+	}
+
+	if isForm {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, vs := range form {
+			if len(vs) > 0 {
+				params[k] = vs[0]
+			}
+		}
+		return params, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	for k, raw := range fields {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			params[k] = s
+			continue
+		}
+		// numbers, booleans and nested values are signed in compact json
+		var buf bytes.Buffer
+		if err = json.Compact(&buf, raw); err != nil {
+			return nil, err
+		}
+		if buf.String() != "null" {
+			params[k] = buf.String()
+		}
+	}
+	return params, nil
+}
+
+// MemoryNonceStore is an in-memory NonceStore, expired nonces are swept lazily
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		nonces: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+func (ms *MemoryNonceStore) CheckAndSet(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	if now.Sub(ms.lastSweep) >= ttl {
+		ms.lastSweep = now
+		for k, expireAt := range ms.nonces {
+			if !now.Before(expireAt) {
+				delete(ms.nonces, k)
+			}
+		}
+	}
+
+	if expireAt, ok := ms.nonces[nonce]; ok && now.Before(expireAt) {
+		return false, nil
+	}
+	ms.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+func (ms *MemoryNonceStore) Len() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return len(ms.nonces)
+}
@@ -0,0 +1,123 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	agw := newTestApiGateway(t)
+	agw.configEcho()
+	handler := func(c echo.Context) error {
+		var body struct {
+			Name  string
+			Count int
+		}
+		if c.Request().Method == http.MethodPost {
+			require.NoError(t, c.Bind(&body))
+		}
+		return SendResp(c, SuccessResp(GetSignAppId(c)+":"+body.Name))
+	}
+	sign := SignWithConfig(SignConfig{KeyStore: StaticSignKeyStore{"app1": "secret1"}})
+	agw.GET("/v1/sign", handler, sign)
+	agw.POST("/v1/sign", handler, sign)
+
+	newParams := func(modify func(p map[string]string)) map[string]string {
+		p := map[string]string{
+			"AppId":     "app1",
+			"Timestamp": strconv.FormatInt(time.Now().Unix(), 10),
+			"Nonce":     strconv.FormatInt(time.Now().UnixNano(), 10),
+			"Name":      "n1",
+		}
+		if modify != nil {
+			modify(p)
+		}
+		if _, ok := p["Sign"]; !ok {
+			p["Sign"] = SignParams(p, "secret1", SignAlgorithmMd5)
+		}
+		return p
+	}
+	get := func(p map[string]string) *httptest.ResponseRecorder {
+		q := url.Values{}
+		for k, v := range p {
+			q.Set(k, v)
+		}
+		return serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/v1/sign?"+q.Encode(), nil))
+	}
+	codeOf := func(rec *httptest.ResponseRecorder) string {
+		var jr JsonResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jr))
+		return jr.Code
+	}
+
+	p := newParams(nil)
+	rec := get(p)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), `"Result":"app1:"`)
+
+	// replay
+	rec = get(p)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, codeExpiredNonce, codeOf(rec))
+
+	rec = get(newParams(func(p map[string]string) { p["Sign"] = "bad" }))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, codeWrongSign, codeOf(rec))
+
+	rec = get(newParams(func(p map[string]string) { p["AppId"] = "unknown" }))
+	require.Equal(t, codeWrongSign, codeOf(rec))
+
+	rec = get(newParams(func(p map[string]string) {
+		p["Timestamp"] = strconv.FormatInt(time.Now().Add(-10*time.Minute).UnixMilli(), 10)
+	}))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, codeExpiredRequest, codeOf(rec))
+
+	rec = get(newParams(func(p map[string]string) { delete(p, "Nonce") }))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, codeInvalidNonce, codeOf(rec))
+
+	// json body is signed and still available to the handler
+	p = newParams(func(p map[string]string) { p["Count"] = "2" })
+	q := url.Values{"Sign": {p["Sign"]}}
+	delete(p, "Sign")
+	body, err := json.Marshal(map[string]any{
+		"AppId": p["AppId"], "Timestamp": p["Timestamp"], "Nonce": p["Nonce"], "Name": "n1", "Count": 2,
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/v1/sign?"+q.Encode(), strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = serveTestRequest(agw, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), `"Result":"app1:n1"`)
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	ns := NewMemoryNonceStore()
+	ns.now = func() time.Time { return now }
+
+	ok, err := ns.CheckAndSet(context.Background(), "n1", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, _ = ns.CheckAndSet(context.Background(), "n1", time.Minute)
+	require.False(t, ok)
+
+	now = now.Add(time.Minute)
+	ok, _ = ns.CheckAndSet(context.Background(), "n2", time.Minute)
+	require.True(t, ok)
+	// n1 is swept
+	require.Equal(t, 1, ns.Len())
+	ok, _ = ns.CheckAndSet(context.Background(), "n1", time.Minute)
+	require.True(t, ok)
+}
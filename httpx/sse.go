@@ -0,0 +1,273 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/errors"
+)
+
+const (
+	MIMETextEventStream = "text/event-stream"
+	HeaderLastEventId   = "Last-Event-ID"
+
+	defaultSSESubscriberBuffer = 64
+)
+
+// SSEConfig defines the config for ServeSSE.
+type SSEConfig struct {
+	// Retry is the reconnection time hinted to the clients, not sent if 0
+	Retry time.Duration `vx_default:"3s"`
+	// Heartbeat sends comments to keep the connection alive through proxies, disabled if 0
+	Heartbeat time.Duration `vx_default:"15s"`
+}
+
+// SSEEvent is an event of Server-Sent Events. Data of string or []byte is sent as is, otherwise
+// it is marshalled to JSON.
+type SSEEvent struct {
+	Id    string
+	Event string
+	Data  any
+	// Retry overrides the reconnection time of the clients if not 0
+	Retry time.Duration
+}
+
+func (ev *SSEEvent) marshal() ([]byte, error) {
+	var data []byte
+	switch d := ev.Data.(type) {
+	case nil:
+	case string:
+		data = []byte(d)
+	case []byte:
+		data = d
+	default:
+		b, err := json.Marshal(d)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		data = b
+	}
+
+	var buf bytes.Buffer
+	if ev.Id != "" {
+		buf.WriteString("id: " + sseLine(ev.Id) + "\n")
+	}
+	if ev.Event != "" {
+		buf.WriteString("event: " + sseLine(ev.Event) + "\n")
+	}
+	if ev.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// sseLine drops the line breaks which would end the field
+func sseLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// LastEventId returns the id of the last event received by the client before reconnecting
+func LastEventId(c echo.Context) string {
+	if id := c.Request().Header.Get(HeaderLastEventId); id != "" {
+		return id
+	}
+	// polyfills of EventSource could not set the header
+	return c.QueryParam("lastEventId")
+}
+
+type sseWriter struct {
+	c echo.Context
+}
+
+func (w *sseWriter) write(b []byte) error {
+	if _, err := w.c.Response().Write(b); err != nil {
+		return errors.Wrap(err)
+	}
+	w.c.Response().Flush()
+	return nil
+}
+
+func (w *sseWriter) send(ev *SSEEvent) error {
+	b, err := ev.marshal()
+	if err != nil {
+		return err
+	}
+	return w.write(b)
+}
+
+func newSSEWriter(c echo.Context, config SSEConfig) (*sseWriter, error) {
+	res := c.Response()
+	// the events are not captured by Logger
+	if bw, ok := res.Writer.(*bodyDumpResponseWriter); ok {
+		res.Writer = bw.ResponseWriter
+	}
+
+	h := res.Header()
+	h.Set(echo.HeaderContentType, MIMETextEventStream)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del(echo.HeaderContentLength)
+	res.WriteHeader(http.StatusOK)
+
+	w := &sseWriter{c: c}
+	if config.Retry > 0 {
+		return w, w.write([]byte("retry: " + strconv.FormatInt(config.Retry.Milliseconds(), 10) + "\n\n"))
+	}
+	w.c.Response().Flush()
+	return w, nil
+}
+
+// ServeSSE sends the events until events is closed, the client disconnects or ApiGateway starts
+// draining, in which case the clients are hinted to reconnect after Retry. It should not be used
+// with Timeout, and the events are not captured by Logger.
+func ServeSSE(c echo.Context, config SSEConfig, events <-chan *SSEEvent) error {
+	return serveSSE(c, config, nil, events)
+}
+
+func serveSSE(c echo.Context, config SSEConfig, replay []*SSEEvent, events <-chan *SSEEvent) error {
+	w, err := newSSEWriter(c, config)
+	if err != nil {
+		return err
+	}
+	for _, ev := range replay {
+		if err := w.send(ev); err != nil {
+			return err
+		}
+	}
+
+	var heartbeat <-chan time.Time
+	if config.Heartbeat > 0 {
+		ticker := time.NewTicker(config.Heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	done := c.Request().Context().Done()
+	drain := DrainNotify(c)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := w.send(ev); err != nil {
+				return err
+			}
+		case <-heartbeat:
+			if err := w.write([]byte(":\n\n")); err != nil {
+				return err
+			}
+		case <-drain:
+			retry := config.Retry
+			if retry <= 0 {
+				retry = time.Second
+			}
+			return w.write([]byte("retry: " + strconv.FormatInt(retry.Milliseconds(), 10) + "\n\n"))
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// SSEStream publishes events to the connected clients, with a bounded replay buffer for the
+// clients reconnecting with Last-Event-ID
+type SSEStream struct {
+	mu          sync.Mutex
+	seq         uint64
+	replay      []*SSEEvent
+	replaySize  int
+	subscribers map[chan *SSEEvent]struct{}
+}
+
+// NewSSEStream creates a SSEStream keeping the last replaySize events
+func NewSSEStream(replaySize int) *SSEStream {
+	return &SSEStream{
+		replaySize:  replaySize,
+		subscribers: make(map[chan *SSEEvent]struct{}),
+	}
+}
+
+// Publish sends the event with a new sequential id to the clients and returns the id. The clients
+// too slow to receive are disconnected, and resume from the replay buffer after reconnecting.
+func (s *SSEStream) Publish(event string, data any) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	ev := &SSEEvent{Id: strconv.FormatUint(s.seq, 10), Event: event, Data: data}
+	if s.replaySize > 0 {
+		if len(s.replay) == s.replaySize {
+			s.replay = append(s.replay[:0], s.replay[1:]...)
+		}
+		s.replay = append(s.replay, ev)
+	}
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ev.Id
+}
+
+// subscribe returns the events after lastEventId in the replay buffer and the channel of new events
+func (s *SSEStream) subscribe(lastEventId string) ([]*SSEEvent, chan *SSEEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var replay []*SSEEvent
+	if last, err := strconv.ParseUint(lastEventId, 10, 64); err == nil {
+		for _, ev := range s.replay {
+			if id, _ := strconv.ParseUint(ev.Id, 10, 64); id > last {
+				replay = append(replay, ev)
+			}
+		}
+	}
+
+	ch := make(chan *SSEEvent, defaultSSESubscriberBuffer)
+	s.subscribers[ch] = struct{}{}
+	return replay, ch
+}
+
+func (s *SSEStream) unsubscribe(ch chan *SSEEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscribers[ch]; ok {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Subscribers returns the count of the connected clients
+func (s *SSEStream) Subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers)
+}
+
+// Serve sends the events missed since LastEventId and the new ones by ServeSSE
+func (s *SSEStream) Serve(c echo.Context, config SSEConfig) error {
+	replay, ch := s.subscribe(LastEventId(c))
+	defer s.unsubscribe(ch)
+	return serveSSE(c, config, replay, ch)
+}
+
+// Handler returns a handler serving s with config
+func (s *SSEStream) Handler(config SSEConfig) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return s.Serve(c, config)
+	}
+}
@@ -0,0 +1,93 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSEEventMarshal(t *testing.T) {
+	b, err := (&SSEEvent{Id: "1", Event: "progress", Data: "a\nb", Retry: time.Second}).marshal()
+	require.NoError(t, err)
+	require.Equal(t, "id: 1\nevent: progress\nretry: 1000\ndata: a\ndata: b\n\n", string(b))
+
+	b, err = (&SSEEvent{Event: "x\ny", Data: map[string]int{"done": 1}}).marshal()
+	require.NoError(t, err)
+	require.Equal(t, "event: xy\ndata: {\"done\":1}\n\n", string(b))
+}
+
+func TestSSEStream(t *testing.T) {
+	var out bytes.Buffer
+	agw := newTestApiGateway(t)
+	agw.LogConf.ContentFormatAfter = "${path} ${body_out}"
+	agw.LogConf.Timing = AccessLogAfterRun
+	agw.Logger.Out = &out
+	agw.configEcho()
+
+	stream := NewSSEStream(2)
+	agw.GET("/events", stream.Handler(SSEConfig{Retry: 3 * time.Second, Heartbeat: 10 * time.Millisecond}))
+	for i := 1; i <= 3; i++ {
+		stream.Publish("progress", i*10)
+	}
+
+	serve := func(req *http.Request) (*httptest.ResponseRecorder, chan struct{}) {
+		rec := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			agw.Echo.ServeHTTP(rec, req)
+			close(done)
+		}()
+		require.Eventually(t, func() bool { return stream.Subscribers() == 1 }, time.Second, time.Millisecond)
+		return rec, done
+	}
+
+	// resumed from Last-Event-ID until the client disconnects
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	req.Header.Set(HeaderLastEventId, "1")
+	rec, done := serve(req)
+	require.Equal(t, "4", stream.Publish("progress", 40))
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+	<-done
+	require.Equal(t, 0, stream.Subscribers())
+
+	require.Equal(t, MIMETextEventStream, rec.Header().Get(echo.HeaderContentType))
+	body := rec.Body.String()
+	require.Contains(t, body, "retry: 3000\n\nid: 2\nevent: progress\ndata: 20\n\nid: 3\nevent: progress\ndata: 30\n\n")
+	require.Contains(t, body, "id: 4\nevent: progress\ndata: 40\n\n")
+	require.NotContains(t, body, "id: 1\n")
+	require.Contains(t, body, ":\n\n")
+	// not captured by Logger
+	require.Contains(t, out.String(), "/events out[")
+	require.NotContains(t, out.String(), "data:")
+
+	// finished by draining
+	rec, done = serve(httptest.NewRequest(http.MethodGet, "/events", nil))
+	agw.drainer.start()
+	<-done
+	require.NotContains(t, rec.Body.String(), "id: 2\n")
+	require.Contains(t, rec.Body.String(), "retry: 3000\n\n")
+}
+
+func TestServeSSE(t *testing.T) {
+	agw := newTestApiGateway(t)
+	agw.configEcho()
+
+	agw.GET("/events", func(c echo.Context) error {
+		events := make(chan *SSEEvent, 2)
+		events <- &SSEEvent{Event: "done", Data: "ok"}
+		close(events)
+		return ServeSSE(c, SSEConfig{}, events)
+	})
+
+	rec := serveTestRequest(agw, httptest.NewRequest(http.MethodGet, "/events", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "event: done\ndata: ok\n\n", rec.Body.String())
+}
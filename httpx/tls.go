@@ -0,0 +1,291 @@
+package httpx
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/errors"
+	"github.com/madlabx/pkgx/log"
+)
+
+const (
+	ctxKeyClientIdentity = "httpx.client_identity"
+)
+
+// TLSConfig enables https on ApiGateway once both CertFile and KeyFile are set.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a PEM bundle used to verify client certificates, required by mutual TLS.
+	ClientCAFile string
+	// MinVersion could be 1.0, 1.1, 1.2, 1.3
+	MinVersion string `vx_default:"1.2"`
+	// ClientAuth could be
+	//
+	// - none
+	// - request
+	// - require
+	// - verify_if_given
+	// - require_and_verify
+	ClientAuth string `vx_default:"none"`
+	// ReloadInterval is the period to check CertFile, KeyFile and ClientCAFile for changes.
+	// 0 disables hot reloading.
+	ReloadInterval time.Duration `vx_default:"1m"`
+}
+
+func (tc *TLSConfig) Enabled() bool {
+	return tc != nil && tc.CertFile != "" && tc.KeyFile != ""
+}
+
+func parseTLSVersion(v string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(v), "tls") {
+	case "1.0", "10":
+		return tls.VersionTLS10, nil
+	case "1.1", "11":
+		return tls.VersionTLS11, nil
+	case "", "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, errors.Errorf("invalid tls version:%v", v)
+	}
+}
+
+func parseClientAuth(s string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "require":
+		return tls.RequireAnyClientCert, nil
+	case "verify_if_given":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require_and_verify":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, errors.Errorf("invalid tls client auth:%v", s)
+	}
+}
+
+// certReloader serves the tls.Config built from TLSConfig, and rebuilds it when any of the
+// files is modified on disk. Failed reloading keeps the previous config.
+type certReloader struct {
+	conf       *TLSConfig
+	nextProtos []string
+	minVersion uint16
+	clientAuth tls.ClientAuthType
+
+	mu        sync.RWMutex
+	current   *tls.Config
+	modTimes  []time.Time
+	nextCheck time.Time
+}
+
+func newCertReloader(tc *TLSConfig, nextProtos []string) (*certReloader, error) {
+	minVersion, err := parseTLSVersion(tc.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	clientAuth, err := parseClientAuth(tc.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	if clientAuth >= tls.VerifyClientCertIfGiven && tc.ClientCAFile == "" {
+		return nil, errors.Errorf("ClientCAFile is required by client auth %v", tc.ClientAuth)
+	}
+
+	cr := &certReloader{
+		conf:       tc,
+		nextProtos: nextProtos,
+		minVersion: minVersion,
+		clientAuth: clientAuth,
+	}
+
+	if err = cr.load(); err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+func (cr *certReloader) files() []string {
+	files := []string{cr.conf.CertFile, cr.conf.KeyFile}
+	if cr.conf.ClientCAFile != "" {
+		files = append(files, cr.conf.ClientCAFile)
+	}
+	return files
+}
+
+func (cr *certReloader) statFiles() ([]time.Time, error) {
+	files := cr.files()
+	modTimes := make([]time.Time, len(files))
+	for i, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		modTimes[i] = fi.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (cr *certReloader) load() error {
+	modTimes, err := cr.statFiles()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.conf.CertFile, cr.conf.KeyFile)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	conf := &tls.Config{
+		MinVersion:   cr.minVersion,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   cr.clientAuth,
+		NextProtos:   cr.nextProtos,
+	}
+
+	if cr.conf.ClientCAFile != "" {
+		pem, err := os.ReadFile(cr.conf.ClientCAFile)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.Errorf("no valid certificate found in %v", cr.conf.ClientCAFile)
+		}
+		conf.ClientCAs = pool
+	}
+
+	cr.mu.Lock()
+	cr.current = conf
+	cr.modTimes = modTimes
+	cr.nextCheck = time.Now().Add(cr.conf.ReloadInterval)
+	cr.mu.Unlock()
+
+	return nil
+}
+
+func (cr *certReloader) changed(modTimes []time.Time) bool {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	for i := range modTimes {
+		if !modTimes[i].Equal(cr.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cr *certReloader) maybeReload() {
+	if cr.conf.ReloadInterval <= 0 {
+		return
+	}
+
+	cr.mu.Lock()
+	if time.Now().Before(cr.nextCheck) {
+		cr.mu.Unlock()
+		return
+	}
+	cr.nextCheck = time.Now().Add(cr.conf.ReloadInterval)
+	cr.mu.Unlock()
+
+	modTimes, err := cr.statFiles()
+	if err != nil {
+		log.Errorf("Failed to check tls files, keep the current certificate, err:%v", err)
+		return
+	}
+
+	if !cr.changed(modTimes) {
+		return
+	}
+
+	if err = cr.load(); err != nil {
+		log.Errorf("Failed to reload tls files, keep the current certificate, err:%v", err)
+		return
+	}
+	log.Infof("Reloaded tls certificate from %v", cr.conf.CertFile)
+}
+
+func (cr *certReloader) config() *tls.Config {
+	cr.maybeReload()
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.current
+}
+
+// TLSConfig returns the tls.Config for http.Server, every handshake picks up the latest certificate
+func (cr *certReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: cr.minVersion,
+		NextProtos: cr.nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return cr.config(), nil
+		},
+	}
+}
+
+// ClientIdentity is the identity carried by the verified client certificate of mutual TLS
+type ClientIdentity struct {
+	CommonName     string
+	Organization   []string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []string
+	SerialNumber   string
+	Issuer         string
+	// Fingerprint is the hex encoded SHA-256 of the raw certificate
+	Fingerprint string
+	// Verified reports whether the certificate was verified against ClientCAFile
+	Verified bool
+}
+
+func newClientIdentity(cs *tls.ConnectionState) *ClientIdentity {
+	if cs == nil || len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+
+	cert := cs.PeerCertificates[0]
+	sum := sha256.Sum256(cert.Raw)
+	ci := &ClientIdentity{
+		CommonName:     cert.Subject.CommonName,
+		Organization:   cert.Subject.Organization,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		SerialNumber:   cert.SerialNumber.String(),
+		Issuer:         cert.Issuer.String(),
+		Fingerprint:    hex.EncodeToString(sum[:]),
+		Verified:       len(cs.VerifiedChains) > 0,
+	}
+	for _, u := range cert.URIs {
+		ci.URIs = append(ci.URIs, u.String())
+	}
+
+	return ci
+}
+
+// GetClientIdentity returns nil if the request is not over TLS or the client sent no certificate
+func GetClientIdentity(c echo.Context) *ClientIdentity {
+	if ci, ok := c.Get(ctxKeyClientIdentity).(*ClientIdentity); ok {
+		return ci
+	}
+
+	ci := newClientIdentity(c.Request().TLS)
+	if ci != nil {
+		c.Set(ctxKeyClientIdentity, ci)
+	}
+	return ci
+}
@@ -0,0 +1,114 @@
+package httpx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSelfSignedCert(t *testing.T, dir, cn string, modTime time.Time) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{cn},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+	return
+}
+
+func leafCommonName(t *testing.T, conf *tls.Config) string {
+	leaf, err := x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeSelfSignedCert(t, dir, "first", now.Add(-time.Minute))
+
+	cr, err := newCertReloader(&TLSConfig{
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		MinVersion:     "1.2",
+		ReloadInterval: time.Millisecond,
+	}, []string{"http/1.1"})
+	require.NoError(t, err)
+
+	conf, err := cr.TLSConfig().GetConfigForClient(nil)
+	require.NoError(t, err)
+	require.Equal(t, "first", leafCommonName(t, conf))
+	require.Equal(t, uint16(tls.VersionTLS12), conf.MinVersion)
+
+	_, _ = writeSelfSignedCert(t, dir, "second", now)
+	time.Sleep(2 * time.Millisecond)
+
+	conf, err = cr.TLSConfig().GetConfigForClient(nil)
+	require.NoError(t, err)
+	require.Equal(t, "second", leafCommonName(t, conf))
+
+	// broken files keep the current certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0600))
+	time.Sleep(2 * time.Millisecond)
+	conf, err = cr.TLSConfig().GetConfigForClient(nil)
+	require.NoError(t, err)
+	require.Equal(t, "second", leafCommonName(t, conf))
+}
+
+func TestNewCertReloaderInvalid(t *testing.T) {
+	_, err := newCertReloader(&TLSConfig{CertFile: "c", KeyFile: "k", MinVersion: "2.0"}, nil)
+	require.Error(t, err)
+
+	_, err = newCertReloader(&TLSConfig{CertFile: "c", KeyFile: "k", ClientAuth: "require_and_verify"}, nil)
+	require.Error(t, err)
+}
+
+func TestGetClientIdentity(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t, t.TempDir(), "client-a", time.Now())
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	require.NoError(t, err)
+
+	e := echo.New()
+	req := httptest.NewRequest("GET", "/", nil)
+	c := e.NewContext(req, httptest.NewRecorder())
+	require.Nil(t, GetClientIdentity(c))
+
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}
+	c = e.NewContext(req, httptest.NewRecorder())
+	ci := GetClientIdentity(c)
+	require.NotNil(t, ci)
+	require.Equal(t, "client-a", ci.CommonName)
+	require.Equal(t, []string{"client-a"}, ci.DNSNames)
+	require.False(t, ci.Verified)
+	require.Len(t, ci.Fingerprint, 64)
+}
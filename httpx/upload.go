@@ -0,0 +1,298 @@
+package httpx
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/errors"
+)
+
+const defaultUploadMaxFieldSize = 64 * 1024
+
+var safeFileExt = regexp.MustCompile(`^\.[0-9A-Za-z]{1,16}$`)
+
+// UploadSink opens the writer of file, e.g. to object storage. If the writer implements
+// interface{ Abort() error }, Abort is called instead of Close when the upload fails.
+type UploadSink func(file *UploadedFile) (io.WriteCloser, error)
+
+// UploadConfig defines the config for Upload.
+type UploadConfig struct {
+	// Dir stores the files with random names if Sink is nil
+	Dir string
+	// Sink opens the writers of the files, see UploadSink
+	Sink UploadSink `json:"-"`
+
+	// MaxFileSize is the max size of each file, e.g. 4K, 2M, no limit if empty
+	MaxFileSize string
+	// MaxTotalSize is the max size of all the files and fields, no limit if empty
+	MaxTotalSize string
+	// MaxFiles is the max count of files, no limit if 0
+	MaxFiles int
+	// MaxFieldSize is the max size of each non-file field
+	MaxFieldSize int64 `vx_default:"65536"`
+
+	// AllowedTypes are the MIME types of the files detected from the content, e.g. image/png,
+	// image/*, any type if empty
+	AllowedTypes []string
+}
+
+// UploadedFile is a file part of the multipart request
+type UploadedFile struct {
+	Field    string
+	Filename string
+	// ContentType is detected from the content instead of the declared one
+	ContentType string
+	Size        int64
+	MD5         string
+	SHA256      string
+	// Path is the stored file in Dir
+	Path string `json:",omitempty"`
+}
+
+// UploadResult is the result of Upload
+type UploadResult struct {
+	Files  []*UploadedFile
+	Fields map[string][]string `json:",omitempty"`
+}
+
+type uploader struct {
+	config       UploadConfig
+	maxFileSize  int64
+	maxTotalSize int64
+	total        int64
+	written      []io.WriteCloser
+	paths        []string
+}
+
+func newUploader(config UploadConfig) (*uploader, error) {
+	if config.Sink == nil && config.Dir == "" {
+		return nil, errors.New("neither Dir nor Sink of UploadConfig is set")
+	}
+	if config.MaxFieldSize <= 0 {
+		config.MaxFieldSize = defaultUploadMaxFieldSize
+	}
+
+	u := &uploader{config: config, maxFileSize: math.MaxInt64 - 1, maxTotalSize: math.MaxInt64 - 1}
+	if n, err := parseBodyLimit(config.MaxFileSize); err != nil {
+		return nil, err
+	} else if n > 0 {
+		u.maxFileSize = n
+	}
+	if n, err := parseBodyLimit(config.MaxTotalSize); err != nil {
+		return nil, err
+	} else if n > 0 {
+		u.maxTotalSize = n
+	}
+	return u, nil
+}
+
+func (u *uploader) allowed(contentType string) bool {
+	if len(u.config.AllowedTypes) == 0 {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, t := range u.config.AllowedTypes {
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if strings.EqualFold(mediaType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *uploader) open(file *UploadedFile) (io.WriteCloser, error) {
+	if u.config.Sink != nil {
+		return u.config.Sink(file)
+	}
+
+	ext := filepath.Ext(file.Filename)
+	if !safeFileExt.MatchString(ext) {
+		ext = ""
+	}
+	f, err := os.CreateTemp(u.config.Dir, "upload-*"+ext)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	file.Path = f.Name()
+	u.paths = append(u.paths, f.Name())
+	return f, nil
+}
+
+// abort discards the files written
+func (u *uploader) abort() {
+	for _, w := range u.written {
+		if a, ok := w.(interface{ Abort() error }); ok {
+			_ = a.Abort()
+		} else {
+			_ = w.Close()
+		}
+	}
+	for _, p := range u.paths {
+		_ = os.Remove(p)
+	}
+}
+
+func tooLarge(format string, args ...any) *JsonResponse {
+	return newErrResp(http.StatusRequestEntityTooLarge, "").WithMsgf(format, args...)
+}
+
+// readErr converts the error of reading the request, e.g. by the limit of BodyLimit
+func readErr(err error) error {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return tooLarge("request body exceeds %v", mbe.Limit)
+	}
+	return BadRequestResp(err)
+}
+
+func (u *uploader) readField(part *multipart.Part, result *UploadResult) error {
+	limit := min(u.config.MaxFieldSize, u.maxTotalSize-u.total)
+	b, err := io.ReadAll(io.LimitReader(part, limit+1))
+	if err != nil {
+		return readErr(err)
+	}
+	if int64(len(b)) > limit {
+		return tooLarge("field %v is too large", part.FormName())
+	}
+	u.total += int64(len(b))
+
+	if result.Fields == nil {
+		result.Fields = make(map[string][]string)
+	}
+	result.Fields[part.FormName()] = append(result.Fields[part.FormName()], string(b))
+	return nil
+}
+
+func (u *uploader) writeFile(part *multipart.Part) (*UploadedFile, error) {
+	file := &UploadedFile{Field: part.FormName(), Filename: part.FileName()}
+
+	fileLimit := min(u.maxFileSize, u.maxTotalSize-u.total)
+	sniff := make([]byte, min(512, fileLimit+1))
+	n, err := io.ReadFull(part, sniff)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, readErr(err)
+	}
+	sniff = sniff[:n]
+	file.ContentType = http.DetectContentType(sniff)
+	if !u.allowed(file.ContentType) {
+		return nil, newErrResp(http.StatusUnsupportedMediaType, "").
+			WithMsgf("type %v of file %v is not allowed", file.ContentType, file.Filename)
+	}
+
+	w, err := u.open(file)
+	if err != nil {
+		return nil, InternalErrorResp(err)
+	}
+	u.written = append(u.written, w)
+
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	mw := io.MultiWriter(w, md5Hash, sha256Hash)
+	if _, err = mw.Write(sniff); err != nil {
+		return nil, InternalErrorResp(err)
+	}
+	copied, err := io.Copy(mw, io.LimitReader(part, fileLimit+1-int64(n)))
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			return nil, readErr(err)
+		}
+		// failures of writing or reading are not distinguishable here
+		return nil, InternalErrorResp(err)
+	}
+
+	file.Size = int64(n) + copied
+	if file.Size > fileLimit {
+		if fileLimit == u.maxFileSize {
+			return nil, tooLarge("file %v exceeds %v", file.Filename, u.config.MaxFileSize)
+		}
+		return nil, tooLarge("files exceed %v", u.config.MaxTotalSize)
+	}
+	u.total += file.Size
+	file.MD5 = hexSum(md5Hash)
+	file.SHA256 = hexSum(sha256Hash)
+	return file, nil
+}
+
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Upload streams the files of the multipart request to config.Dir or config.Sink without buffering
+// them in memory, while checking the limits and MIME types and computing MD5 and SHA-256. The error
+// is a *JsonResponse to be sent by SendResp, e.g. 413 for the limits and 415 for the types. On error,
+// the files stored in Dir are removed and the writer of Sink being written is aborted.
+func Upload(c echo.Context, config UploadConfig) (result *UploadResult, err error) {
+	u, err := newUploader(config)
+	if err != nil {
+		return nil, InternalErrorResp(err)
+	}
+
+	mr, err := c.Request().MultipartReader()
+	if err != nil {
+		return nil, BadRequestResp(err)
+	}
+
+	defer func() {
+		if err != nil {
+			u.abort()
+		}
+	}()
+
+	result = &UploadResult{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, readErr(err)
+		}
+
+		if part.FileName() == "" {
+			err = u.readField(part, result)
+		} else if config.MaxFiles > 0 && len(result.Files) >= config.MaxFiles {
+			err = tooLarge("too many files, max %v", config.MaxFiles)
+		} else {
+			var file *UploadedFile
+			if file, err = u.writeFile(part); err == nil {
+				result.Files = append(result.Files, file)
+				err = u.written[len(u.written)-1].Close()
+				u.written = u.written[:len(u.written)-1]
+				if err != nil {
+					err = InternalErrorResp(err)
+				}
+			}
+		}
+		_ = part.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// UploadHandler returns a handler responding the UploadResult of Upload as Result of SuccessResp
+func UploadHandler(config UploadConfig) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		result, err := Upload(c, config)
+		if err != nil {
+			return SendResp(c, err)
+		}
+		return SendResp(c, SuccessResp(result))
+	}
+}
@@ -0,0 +1,136 @@
+package httpx
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testUploadSink struct {
+	bytes.Buffer
+	closed, aborted bool
+}
+
+func (s *testUploadSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *testUploadSink) Abort() error {
+	s.aborted = true
+	return nil
+}
+
+func newTestUploadRequest(t *testing.T, fields map[string]string, files ...string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		require.NoError(t, mw.WriteField(k, v))
+	}
+	for i := 0; i+1 < len(files); i += 2 {
+		w, err := mw.CreateFormFile("file", files[i])
+		require.NoError(t, err)
+		_, err = w.Write([]byte(files[i+1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUpload(t *testing.T) {
+	dir := t.TempDir()
+	agw := newTestApiGateway(t)
+	agw.configEcho()
+	agw.POST("/upload", UploadHandler(UploadConfig{
+		Dir:          dir,
+		MaxFileSize:  "16B",
+		MaxTotalSize: "32B",
+		MaxFiles:     2,
+		AllowedTypes: []string{"text/*"},
+	}))
+
+	content := "hello upload"
+	rec := serveTestRequest(agw, newTestUploadRequest(t, map[string]string{"name": "bob"}, "../a.txt", content))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp struct {
+		Result UploadResult
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, []string{"bob"}, resp.Result.Fields["name"])
+	require.Len(t, resp.Result.Files, 1)
+	f := resp.Result.Files[0]
+	md5Sum, sha256Sum := md5.Sum([]byte(content)), sha256.Sum256([]byte(content))
+	require.Equal(t, "file", f.Field)
+	require.Equal(t, "a.txt", f.Filename)
+	require.Equal(t, "text/plain; charset=utf-8", f.ContentType)
+	require.Equal(t, int64(len(content)), f.Size)
+	require.Equal(t, hex.EncodeToString(md5Sum[:]), f.MD5)
+	require.Equal(t, hex.EncodeToString(sha256Sum[:]), f.SHA256)
+	require.True(t, strings.HasPrefix(f.Path, dir))
+	require.True(t, strings.HasSuffix(f.Path, ".txt"))
+	b, err := os.ReadFile(f.Path)
+	require.NoError(t, err)
+	require.Equal(t, content, string(b))
+	require.NoError(t, os.Remove(f.Path))
+
+	testCases := []struct {
+		name   string
+		files  []string
+		status int
+	}{
+		{"FileTooLarge", []string{"a.txt", strings.Repeat("x", 17)}, http.StatusRequestEntityTooLarge},
+		{"TotalTooLarge", []string{"a.txt", strings.Repeat("x", 16), "b.txt", strings.Repeat("x", 16)}, http.StatusRequestEntityTooLarge},
+		{"TooManyFiles", []string{"a.txt", "a", "b.txt", "b", "c.txt", "c"}, http.StatusRequestEntityTooLarge},
+		{"NotAllowedType", []string{"a.txt", "\x89PNG\r\n\x1a\n"}, http.StatusUnsupportedMediaType},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveTestRequest(agw, newTestUploadRequest(t, map[string]string{"name": "bob"}, tc.files...))
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+			// the files written are removed
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			require.Empty(t, entries)
+		})
+	}
+
+	rec = serveTestRequest(agw, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("{}")))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestUploadSink(t *testing.T) {
+	var sinks []*testUploadSink
+	agw := newTestApiGateway(t)
+	agw.configEcho()
+	agw.POST("/upload", UploadHandler(UploadConfig{
+		MaxFileSize: "8B",
+		Sink: func(file *UploadedFile) (io.WriteCloser, error) {
+			s := &testUploadSink{}
+			sinks = append(sinks, s)
+			return s, nil
+		},
+	}))
+
+	rec := serveTestRequest(agw, newTestUploadRequest(t, nil, "a.bin", "12345678", "b.bin", "123456789"))
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	require.Len(t, sinks, 2)
+	require.Equal(t, "12345678", sinks[0].String())
+	require.True(t, sinks[0].closed)
+	require.True(t, sinks[1].aborted)
+	require.False(t, sinks[1].closed)
+}
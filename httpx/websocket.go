@@ -0,0 +1,433 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo"
+	"github.com/madlabx/pkgx/errors"
+)
+
+const (
+	defaultWSSendQueueSize  = 256
+	defaultWSWriteTimeout   = 10 * time.Second
+	defaultWSPongTimeout    = 60 * time.Second
+	defaultWSMaxMessageSize = 64 * 1024
+)
+
+var (
+	ErrWSQueueFull = errors.New("websocket send queue is full")
+	ErrWSClosed    = errors.New("websocket connection is closed")
+)
+
+// WebSocketConfig defines the config for Hub.
+type WebSocketConfig struct {
+	// AllowOrigins are the origins allowed to connect, e.g. https://*.example.com, the same
+	// origin as Host only if empty
+	AllowOrigins []string
+
+	ReadBufferSize  int
+	WriteBufferSize int
+	// MaxMessageSize is the max size of the messages from clients
+	MaxMessageSize int64 `vx_default:"65536"`
+
+	// SendQueueSize is the count of messages queued for each connection, the connection is
+	// closed if its queue is full
+	SendQueueSize int           `vx_default:"256"`
+	WriteTimeout  time.Duration `vx_default:"10s"`
+	// PongTimeout closes the connection without pong for it, pings are sent every 9/10 of it
+	PongTimeout time.Duration `vx_default:"60s"`
+
+	// OnConnect is called once upgraded, the connection is rejected if it returns error,
+	// e.g. to join the rooms by the claims of JWT
+	OnConnect func(conn *WSConn) error `json:"-"`
+	// OnMessage handles the messages from clients sequentially for each connection
+	OnMessage func(conn *WSConn, messageType int, data []byte) `json:"-"`
+	OnClose   func(conn *WSConn)                               `json:"-"`
+}
+
+type wsMessage struct {
+	messageType int
+	data        []byte
+}
+
+// WSConn is a websocket connection of Hub
+type WSConn struct {
+	hub  *Hub
+	conn *websocket.Conn
+	c    echo.Context
+
+	send      chan wsMessage
+	done      chan struct{}
+	closeOnce sync.Once
+	closeCode int
+
+	mu    sync.Mutex
+	rooms map[string]struct{}
+}
+
+// Context returns the echo.Context of the upgrade request, e.g. for GetJWTClaims, which is valid
+// until the connection is closed
+func (wc *WSConn) Context() echo.Context {
+	return wc.c
+}
+
+// Send queues the message without blocking, the connection is closed if the queue is full
+func (wc *WSConn) Send(messageType int, data []byte) error {
+	select {
+	case <-wc.done:
+		return ErrWSClosed
+	default:
+	}
+
+	select {
+	case wc.send <- wsMessage{messageType: messageType, data: data}:
+		return nil
+	default:
+		wc.close(websocket.ClosePolicyViolation)
+		return ErrWSQueueFull
+	}
+}
+
+// SendJSON queues v marshalled as a text message
+func (wc *WSConn) SendJSON(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return wc.Send(websocket.TextMessage, b)
+}
+
+// Join adds the connection to room
+func (wc *WSConn) Join(room string) {
+	wc.hub.join(wc, room)
+}
+
+// Leave removes the connection from room
+func (wc *WSConn) Leave(room string) {
+	wc.hub.leave(wc, room)
+}
+
+// Close sends the close frame and closes the connection
+func (wc *WSConn) Close() {
+	wc.close(websocket.CloseNormalClosure)
+}
+
+func (wc *WSConn) close(code int) {
+	wc.closeOnce.Do(func() {
+		wc.closeCode = code
+		close(wc.done)
+	})
+}
+
+func (wc *WSConn) readPump() {
+	defer wc.close(websocket.CloseNormalClosure)
+
+	config := wc.hub.config
+	wc.conn.SetReadLimit(config.MaxMessageSize)
+	_ = wc.conn.SetReadDeadline(time.Now().Add(config.PongTimeout))
+	wc.conn.SetPongHandler(func(string) error {
+		return wc.conn.SetReadDeadline(time.Now().Add(config.PongTimeout))
+	})
+
+	for {
+		messageType, data, err := wc.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if config.OnMessage != nil {
+			config.OnMessage(wc, messageType, data)
+		}
+	}
+}
+
+func (wc *WSConn) writePump(drain <-chan struct{}) {
+	config := wc.hub.config
+	ticker := time.NewTicker(config.PongTimeout * 9 / 10)
+	defer func() {
+		ticker.Stop()
+		// unblock readPump
+		_ = wc.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg := <-wc.send:
+			_ = wc.conn.SetWriteDeadline(time.Now().Add(config.WriteTimeout))
+			if err := wc.conn.WriteMessage(msg.messageType, msg.data); err != nil {
+				wc.close(websocket.CloseAbnormalClosure)
+				return
+			}
+		case <-ticker.C:
+			if err := wc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(config.WriteTimeout)); err != nil {
+				wc.close(websocket.CloseAbnormalClosure)
+				return
+			}
+		case <-drain:
+			wc.close(websocket.CloseGoingAway)
+			drain = nil
+		case <-wc.done:
+			if wc.closeCode != websocket.CloseAbnormalClosure {
+				_ = wc.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(wc.closeCode, ""),
+					time.Now().Add(config.WriteTimeout))
+			}
+			return
+		}
+	}
+}
+
+// Hub manages the websocket connections and their rooms
+type Hub struct {
+	config   WebSocketConfig
+	upgrader websocket.Upgrader
+
+	mu     sync.RWMutex
+	conns  map[*WSConn]struct{}
+	rooms  map[string]map[*WSConn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewHub creates a Hub, whose Handler should be registered on the routes of ApiGateway with the
+// middleware for authentication, e.g.
+//
+//	hub := httpx.NewHub(httpx.WebSocketConfig{OnMessage: onMessage})
+//	agw.GET("/ws", hub.Handler(), httpx.JWT(key))
+//
+// The connections are closed when ApiGateway starts draining or by Stop.
+func NewHub(config WebSocketConfig) *Hub {
+	if config.MaxMessageSize <= 0 {
+		config.MaxMessageSize = defaultWSMaxMessageSize
+	}
+	if config.SendQueueSize <= 0 {
+		config.SendQueueSize = defaultWSSendQueueSize
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = defaultWSWriteTimeout
+	}
+	if config.PongTimeout <= 0 {
+		config.PongTimeout = defaultWSPongTimeout
+	}
+
+	h := &Hub{
+		config: config,
+		conns:  make(map[*WSConn]struct{}),
+		rooms:  make(map[string]map[*WSConn]struct{}),
+	}
+	h.upgrader = websocket.Upgrader{
+		ReadBufferSize:  config.ReadBufferSize,
+		WriteBufferSize: config.WriteBufferSize,
+		CheckOrigin:     h.checkOrigin,
+	}
+	return h
+}
+
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get(echo.HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+	if len(h.config.AllowOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, pattern := range h.config.AllowOrigins {
+		if matchOriginPattern(origin, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler upgrades the requests and serves the connections until closed
+func (h *Hub) Handler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		h.mu.RLock()
+		closed := h.closed
+		h.mu.RUnlock()
+		if closed {
+			return SendResp(c, newErrResp(http.StatusServiceUnavailable, ""))
+		}
+
+		conn, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
+		if err != nil {
+			// the error is responded by upgrader
+			return nil
+		}
+
+		wc := &WSConn{
+			hub:   h,
+			conn:  conn,
+			c:     c,
+			send:  make(chan wsMessage, h.config.SendQueueSize),
+			done:  make(chan struct{}),
+			rooms: make(map[string]struct{}),
+		}
+		if !h.add(wc) {
+			wc.close(websocket.CloseGoingAway)
+		} else if h.config.OnConnect != nil {
+			if err := h.config.OnConnect(wc); err != nil {
+				wc.close(websocket.ClosePolicyViolation)
+			}
+		}
+		defer h.remove(wc)
+
+		writeDone := make(chan struct{})
+		go func() {
+			wc.writePump(DrainNotify(c))
+			close(writeDone)
+		}()
+		wc.readPump()
+		<-writeDone
+
+		if h.config.OnClose != nil {
+			h.config.OnClose(wc)
+		}
+		return nil
+	}
+}
+
+func (h *Hub) add(wc *WSConn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	h.conns[wc] = struct{}{}
+	h.wg.Add(1)
+	return true
+}
+
+func (h *Hub) remove(wc *WSConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.conns[wc]; !ok {
+		return
+	}
+	delete(h.conns, wc)
+
+	wc.mu.Lock()
+	for room := range wc.rooms {
+		h.leaveLocked(wc, room)
+	}
+	wc.mu.Unlock()
+	h.wg.Done()
+}
+
+func (h *Hub) join(wc *WSConn, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.conns[wc]; !ok {
+		return
+	}
+	if h.rooms[room] == nil {
+		h.rooms[room] = make(map[*WSConn]struct{})
+	}
+	h.rooms[room][wc] = struct{}{}
+
+	wc.mu.Lock()
+	wc.rooms[room] = struct{}{}
+	wc.mu.Unlock()
+}
+
+func (h *Hub) leave(wc *WSConn, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	h.leaveLocked(wc, room)
+}
+
+func (h *Hub) leaveLocked(wc *WSConn, room string) {
+	delete(wc.rooms, room)
+	if members := h.rooms[room]; members != nil {
+		delete(members, wc)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+func (h *Hub) broadcast(conns map[*WSConn]struct{}, messageType int, data []byte) {
+	for wc := range conns {
+		// the slow connections are closed by Send
+		_ = wc.Send(messageType, data)
+	}
+}
+
+// Broadcast sends the message to all the connections
+func (h *Hub) Broadcast(messageType int, data []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	h.broadcast(h.conns, messageType, data)
+}
+
+// BroadcastTo sends the message to the connections in room
+func (h *Hub) BroadcastTo(room string, messageType int, data []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	h.broadcast(h.rooms[room], messageType, data)
+}
+
+// BroadcastJSON sends v marshalled as a text message to the connections in rooms, or all the
+// connections if rooms is empty
+func (h *Hub) BroadcastJSON(v any, rooms ...string) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if len(rooms) == 0 {
+		h.Broadcast(websocket.TextMessage, b)
+	}
+	for _, room := range rooms {
+		h.BroadcastTo(room, websocket.TextMessage, b)
+	}
+	return nil
+}
+
+// Len returns the count of the connections
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.conns)
+}
+
+// RoomLen returns the count of the connections in room
+func (h *Hub) RoomLen(room string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.rooms[room])
+}
+
+// Name implements graceful.GracefulService
+func (h *Hub) Name() string {
+	return "websocket hub"
+}
+
+// Stop rejects new connections, closes the current ones with 1001 going away and waits for them,
+// it implements graceful.GracefulService
+func (h *Hub) Stop() error {
+	h.mu.Lock()
+	h.closed = true
+	for wc := range h.conns {
+		wc.close(websocket.CloseGoingAway)
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(h.config.WriteTimeout):
+		return errors.Errorf("%d websocket connections not closed in %v", h.Len(), h.config.WriteTimeout)
+	}
+}
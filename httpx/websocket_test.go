@@ -0,0 +1,112 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWSConnBackpressure(t *testing.T) {
+	wc := &WSConn{send: make(chan wsMessage, 1), done: make(chan struct{})}
+	require.NoError(t, wc.Send(websocket.TextMessage, []byte("1")))
+	require.ErrorIs(t, wc.Send(websocket.TextMessage, []byte("2")), ErrWSQueueFull)
+	require.Equal(t, websocket.ClosePolicyViolation, wc.closeCode)
+	require.ErrorIs(t, wc.Send(websocket.TextMessage, []byte("3")), ErrWSClosed)
+}
+
+func TestHub(t *testing.T) {
+	secret := []byte("secret")
+	agw := newTestApiGateway(t)
+	agw.configEcho()
+
+	hub := NewHub(WebSocketConfig{
+		PongTimeout: time.Second,
+		OnConnect: func(conn *WSConn) error {
+			claims, _ := GetJWTClaimsAs[*testUserClaims](conn.Context())
+			conn.Join("user:" + claims.UserId)
+			conn.Join("all")
+			return nil
+		},
+		OnMessage: func(conn *WSConn, messageType int, data []byte) {
+			_ = conn.Send(messageType, append([]byte("echo:"), data...))
+		},
+	})
+	agw.GET("/ws", hub.Handler(), JWTWithConfig(JWTConfig{
+		SigningKey: secret,
+		NewClaims:  func() JWTClaimsIf { return &testUserClaims{} },
+	}))
+	drainHub := NewHub(WebSocketConfig{})
+	agw.GET("/ws2", drainHub.Handler())
+
+	url := strings.Replace(startTestServer(t, agw), "http://", "ws://", 1)
+	dial := func(path, uid string) (*websocket.Conn, int) {
+		header := http.Header{}
+		if uid != "" {
+			claims := &testUserClaims{UserId: uid}
+			claims.ExpiresAt = time.Now().Add(time.Hour).Unix()
+			header.Set(echo.HeaderAuthorization, "Bearer "+signTestJwt(t, jwt.SigningMethodHS256, secret, "", claims))
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(url+path, header)
+		if err != nil {
+			require.NotNil(t, resp, err)
+			return nil, resp.StatusCode
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn, resp.StatusCode
+	}
+	read := func(conn *websocket.Conn) string {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// authenticated by the middleware of the route
+	_, resp, err := websocket.DefaultDialer.Dial(url+"/ws", nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	// cross origin
+	_, resp, err = websocket.DefaultDialer.Dial(url+"/ws2", http.Header{"Origin": []string{"https://evil.example.com"}})
+	require.Error(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	c1, _ := dial("/ws", "u1")
+	c2, _ := dial("/ws", "u2")
+	require.Eventually(t, func() bool { return hub.RoomLen("all") == 2 }, time.Second, time.Millisecond)
+
+	require.NoError(t, c1.WriteMessage(websocket.TextMessage, []byte("hi")))
+	require.Equal(t, "echo:hi", read(c1))
+
+	hub.BroadcastTo("user:u2", websocket.TextMessage, []byte("to u2"))
+	require.Equal(t, "to u2", read(c2))
+	require.NoError(t, hub.BroadcastJSON(map[string]string{"to": "all"}))
+	require.Equal(t, `{"to":"all"}`, read(c1))
+	require.Equal(t, `{"to":"all"}`, read(c2))
+
+	// stopped by graceful
+	require.NoError(t, hub.Stop())
+	require.Equal(t, 0, hub.Len())
+	require.Equal(t, 0, hub.RoomLen("all"))
+	_ = c1.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = c1.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+	_, status := dial("/ws", "u1")
+	require.Equal(t, http.StatusServiceUnavailable, status)
+
+	// closed by draining of ApiGateway
+	c3, _ := dial("/ws2", "")
+	require.Eventually(t, func() bool { return drainHub.Len() == 1 }, time.Second, time.Millisecond)
+	report, err := agw.Shutdown(context.Background())
+	require.NoError(t, err)
+	require.True(t, report.Drained)
+	_ = c3.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = c3.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), err)
+}
@@ -0,0 +1,55 @@
+package log
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FieldKeyRequestId is the field name of request id in log entries
+const FieldKeyRequestId = "request_id"
+
+type requestIdKey struct{}
+
+// ContextWithRequestId returns a copy of ctx carrying the request id
+func ContextWithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestIdFromContext returns "" if ctx carries no request id
+func RequestIdFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// WithContext returns an entry of the standard logger, the request id carried by ctx is added as field
+func WithContext(ctx context.Context) *logrus.Entry {
+	e := logrus.WithContext(ctx)
+	if id := RequestIdFromContext(ctx); id != "" {
+		e = e.WithField(FieldKeyRequestId, id)
+	}
+	return e
+}
+
+// RequestIdHook adds the request id carried by Entry.Context as a field, so that
+// logger.WithContext(ctx) picks up the request id automatically. It is installed by New,
+// add it to other loggers explicitly, e.g. logrus.AddHook(&RequestIdHook{}).
+type RequestIdHook struct{}
+
+func (h *RequestIdHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *RequestIdHook) Fire(e *logrus.Entry) error {
+	if _, ok := e.Data[FieldKeyRequestId]; ok {
+		return nil
+	}
+
+	if id := RequestIdFromContext(e.Context); id != "" {
+		e.Data[FieldKeyRequestId] = id
+	}
+	return nil
+}
@@ -43,7 +43,9 @@ type Logger struct {
 }
 
 func New() *Logger {
-	return &Logger{logrus.New()}
+	lg := logrus.New()
+	lg.AddHook(&RequestIdHook{})
+	return &Logger{lg}
 }
 
 func NewLogger(pCtx context.Context, cfg FileConfig) *Logger {
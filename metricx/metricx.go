@@ -0,0 +1,467 @@
+// Package metricx is a minimal in-process metrics registry, exposed in Prometheus text format
+// without depending on the prometheus client.
+package metricx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+
+	labelValueSeparator = "\xff"
+)
+
+var (
+	// DefBuckets are latency buckets in seconds
+	DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	// SizeBuckets are body size buckets in bytes, from 64B to 64MB
+	SizeBuckets = ExponentialBuckets(64, 4, 11)
+
+	defaultRegistry = NewRegistry()
+)
+
+// ExponentialBuckets returns count buckets, the first is start and each one is factor times the previous
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// Default returns the process wide registry
+func Default() *Registry {
+	return defaultRegistry
+}
+
+type family interface {
+	desc() *metricDesc
+	write(w *bufio.Writer)
+}
+
+type metricDesc struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+}
+
+func (md *metricDesc) sameAs(other *metricDesc) bool {
+	return md.typ == other.typ && strings.Join(md.labelNames, ",") == strings.Join(other.labelNames, ",")
+}
+
+func (md *metricDesc) writeHeader(w *bufio.Writer) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", md.name, escapeHelp(md.help), md.name, md.typ)
+}
+
+// Registry holds metric families by name, it is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// register returns the existing family of the same name, panics if it is of another type or labels
+func (r *Registry) register(md *metricDesc, newFamily func() family) family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[md.name]; ok {
+		if !f.desc().sameAs(md) {
+			panic(fmt.Sprintf("metric %s registered with different type or labels", md.name))
+		}
+		return f
+	}
+
+	f := newFamily()
+	r.families[md.name] = f
+	return f
+}
+
+// NewCounterVec registers a counter, or returns the registered one of the same name
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	md := &metricDesc{name: name, help: help, typ: typeCounter, labelNames: labelNames}
+	return r.register(md, func() family {
+		return &CounterVec{vec: newVec(md, func() *value { return &value{} })}
+	}).(*CounterVec)
+}
+
+// NewGaugeVec registers a gauge, or returns the registered one of the same name
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	md := &metricDesc{name: name, help: help, typ: typeGauge, labelNames: labelNames}
+	return r.register(md, func() family {
+		return &GaugeVec{vec: newVec(md, func() *value { return &value{} })}
+	}).(*GaugeVec)
+}
+
+// NewHistogramVec registers a histogram, or returns the registered one of the same name
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	md := &metricDesc{name: name, help: help, typ: typeHistogram, labelNames: labelNames}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return r.register(md, func() family {
+		return &HistogramVec{vec: newVec(md, func() *Histogram {
+			return &Histogram{upperBounds: buckets, counts: make([]uint64, len(buckets))}
+		})}
+	}).(*HistogramVec)
+}
+
+// NewGaugeFunc registers a gauge whose value is collected by fn on every scrape.
+// Gauge funcs of the same name are distinguished by labels.
+func (r *Registry) NewGaugeFunc(name, help string, labels Labels, fn func() float64) {
+	labelNames := labels.names()
+	md := &metricDesc{name: name, help: help, typ: typeGauge, labelNames: labelNames}
+	gf := r.register(md, func() family {
+		return &gaugeFuncFamily{md: md, funcs: make(map[string]func() float64)}
+	}).(*gaugeFuncFamily)
+
+	gf.mu.Lock()
+	gf.funcs[labels.key(labelNames)] = fn
+	gf.mu.Unlock()
+}
+
+// WriteTo writes all metrics in Prometheus text exposition format
+func (r *Registry) WriteTo(out io.Writer) (int64, error) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	families := make([]family, len(names))
+	for i, name := range names {
+		families[i] = r.families[name]
+	}
+	r.mu.RUnlock()
+
+	cw := &countWriter{w: out}
+	w := bufio.NewWriter(cw)
+	for _, f := range families {
+		f.write(w)
+	}
+	err := w.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP implements http.Handler
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// Labels are constant labels of a gauge func
+type Labels map[string]string
+
+func (l Labels) names() []string {
+	names := make([]string, 0, len(l))
+	for k := range l {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (l Labels) key(names []string) string {
+	values := make([]string, len(names))
+	for i, n := range names {
+		values[i] = l[n]
+	}
+	return strings.Join(values, labelValueSeparator)
+}
+
+type vec[T any] struct {
+	md       *metricDesc
+	newValue func() T
+	mu       sync.RWMutex
+	series   map[string]T
+}
+
+func newVec[T any](md *metricDesc, newValue func() T) *vec[T] {
+	return &vec[T]{md: md, newValue: newValue, series: make(map[string]T)}
+}
+
+func (v *vec[T]) desc() *metricDesc {
+	return v.md
+}
+
+func (v *vec[T]) with(labelValues []string) T {
+	if len(labelValues) != len(v.md.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.md.name, len(v.md.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, labelValueSeparator)
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok = v.series[key]; !ok {
+		s = v.newValue()
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted returns label values and series in the order of keys
+func (v *vec[T]) sorted() ([][]string, []T) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	labelValues := make([][]string, len(keys))
+	series := make([]T, len(keys))
+	for i, k := range keys {
+		if len(v.md.labelNames) > 0 {
+			labelValues[i] = strings.Split(k, labelValueSeparator)
+		}
+		series[i] = v.series[k]
+	}
+	return labelValues, series
+}
+
+func (v *vec[T]) writeValues(w *bufio.Writer, get func(T) float64) {
+	v.md.writeHeader(w)
+	labelValues, series := v.sorted()
+	for i, s := range series {
+		writeSample(w, v.md.name, v.md.labelNames, labelValues[i], "", "", get(s))
+	}
+}
+
+// value is a float64 updated atomically
+type value struct {
+	bits uint64
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		if atomic.CompareAndSwapUint64(&v.bits, old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (v *value) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&v.bits))
+}
+
+type Counter struct{ v *value }
+
+func (c Counter) Inc() { c.v.add(1) }
+
+// Add panics if delta is negative
+func (c Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("counter cannot decrease")
+	}
+	c.v.add(delta)
+}
+
+func (c Counter) Value() float64 { return c.v.load() }
+
+type CounterVec struct {
+	*vec[*value]
+}
+
+// With returns the counter of labelValues, in the order of labelNames
+func (cv *CounterVec) With(labelValues ...string) Counter {
+	return Counter{cv.with(labelValues)}
+}
+
+func (cv *CounterVec) write(w *bufio.Writer) {
+	cv.writeValues(w, (*value).load)
+}
+
+type Gauge struct{ v *value }
+
+func (g Gauge) Inc()              { g.v.add(1) }
+func (g Gauge) Dec()              { g.v.add(-1) }
+func (g Gauge) Add(delta float64) { g.v.add(delta) }
+func (g Gauge) Set(v float64)     { atomic.StoreUint64(&g.v.bits, math.Float64bits(v)) }
+func (g Gauge) Value() float64    { return g.v.load() }
+
+type GaugeVec struct {
+	*vec[*value]
+}
+
+// With returns the gauge of labelValues, in the order of labelNames
+func (gv *GaugeVec) With(labelValues ...string) Gauge {
+	return Gauge{gv.with(labelValues)}
+}
+
+func (gv *GaugeVec) write(w *bufio.Writer) {
+	gv.writeValues(w, (*value).load)
+}
+
+type Histogram struct {
+	mu          sync.Mutex
+	upperBounds []float64
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.upperBounds, v)
+	h.mu.Lock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+// snapshot returns cumulative bucket counts, count and sum
+func (h *Histogram) snapshot() ([]uint64, uint64, float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cumulative := make([]uint64, len(h.counts))
+	var acc uint64
+	for i, c := range h.counts {
+		acc += c
+		cumulative[i] = acc
+	}
+	return cumulative, h.count, h.sum
+}
+
+type HistogramVec struct {
+	*vec[*Histogram]
+}
+
+// With returns the histogram of labelValues, in the order of labelNames
+func (hv *HistogramVec) With(labelValues ...string) *Histogram {
+	return hv.with(labelValues)
+}
+
+func (hv *HistogramVec) write(w *bufio.Writer) {
+	hv.md.writeHeader(w)
+	labelValues, series := hv.sorted()
+	for i, h := range series {
+		cumulative, count, sum := h.snapshot()
+		for j, ub := range h.upperBounds {
+			writeSample(w, hv.md.name+"_bucket", hv.md.labelNames, labelValues[i], "le", formatFloat(ub), float64(cumulative[j]))
+		}
+		writeSample(w, hv.md.name+"_bucket", hv.md.labelNames, labelValues[i], "le", "+Inf", float64(count))
+		writeSample(w, hv.md.name+"_sum", hv.md.labelNames, labelValues[i], "", "", sum)
+		writeSample(w, hv.md.name+"_count", hv.md.labelNames, labelValues[i], "", "", float64(count))
+	}
+}
+
+type gaugeFuncFamily struct {
+	md    *metricDesc
+	mu    sync.RWMutex
+	funcs map[string]func() float64
+}
+
+func (gf *gaugeFuncFamily) desc() *metricDesc {
+	return gf.md
+}
+
+func (gf *gaugeFuncFamily) write(w *bufio.Writer) {
+	gf.md.writeHeader(w)
+	gf.mu.RLock()
+	keys := make([]string, 0, len(gf.funcs))
+	for k := range gf.funcs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fns := make([]func() float64, len(keys))
+	for i, k := range keys {
+		fns[i] = gf.funcs[k]
+	}
+	gf.mu.RUnlock()
+
+	for i, k := range keys {
+		var labelValues []string
+		if len(gf.md.labelNames) > 0 {
+			labelValues = strings.Split(k, labelValueSeparator)
+		}
+		writeSample(w, gf.md.name, gf.md.labelNames, labelValues, "", "", fns[i]())
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, extraName, extraValue string, v float64) {
+	_, _ = w.WriteString(name)
+	if len(labelNames) > 0 || extraName != "" {
+		_ = w.WriteByte('{')
+		for i, ln := range labelNames {
+			if i > 0 {
+				_ = w.WriteByte(',')
+			}
+			_, _ = fmt.Fprintf(w, "%s=\"%s\"", ln, escapeLabelValue(labelValues[i]))
+		}
+		if extraName != "" {
+			if len(labelNames) > 0 {
+				_ = w.WriteByte(',')
+			}
+			_, _ = fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		_ = w.WriteByte('}')
+	}
+	_ = w.WriteByte(' ')
+	_, _ = w.WriteString(formatFloat(v))
+	_ = w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
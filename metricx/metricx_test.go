@@ -0,0 +1,60 @@
+package metricx
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	reg := NewRegistry()
+
+	c := reg.NewCounterVec("requests_total", "Total requests.", "method", "status")
+	c.With("GET", "200").Inc()
+	c.With("GET", "200").Add(2)
+	c.With("POST", "500").Inc()
+	require.Equal(t, float64(3), c.With("GET", "200").Value())
+
+	// same name returns the registered one
+	require.Same(t, c.vec, reg.NewCounterVec("requests_total", "Total requests.", "method", "status").vec)
+	require.Panics(t, func() { reg.NewGaugeVec("requests_total", "", "method") })
+
+	g := reg.NewGaugeVec("in_flight", "In flight \"requests\".")
+	g.With().Inc()
+	g.With().Inc()
+	g.With().Dec()
+
+	h := reg.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	h.With(`/a"b`).Observe(0.05)
+	h.With(`/a"b`).Observe(0.5)
+	h.With(`/a"b`).Observe(5)
+
+	reg.NewGaugeFunc("pool_conns", "Conns.", Labels{"address": "b"}, func() float64 { return 2 })
+	reg.NewGaugeFunc("pool_conns", "Conns.", Labels{"address": "a"}, func() float64 { return 1 })
+
+	var buf bytes.Buffer
+	_, err := reg.WriteTo(&buf)
+	require.NoError(t, err)
+
+	expected := `# HELP in_flight In flight "requests".
+# TYPE in_flight gauge
+in_flight 1
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a\"b",le="0.1"} 1
+latency_seconds_bucket{route="/a\"b",le="1"} 2
+latency_seconds_bucket{route="/a\"b",le="+Inf"} 3
+latency_seconds_sum{route="/a\"b"} 5.55
+latency_seconds_count{route="/a\"b"} 3
+# HELP pool_conns Conns.
+# TYPE pool_conns gauge
+pool_conns{address="a"} 1
+pool_conns{address="b"} 2
+# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{method="GET",status="200"} 3
+requests_total{method="POST",status="500"} 1
+`
+	require.Equal(t, expected, buf.String())
+}
@@ -35,9 +35,13 @@ func GetFieldValue(sv any, fieldName string) (any, error) {
 
 // GetSignString  ignore Sign in r
 func GetSignString(r any) string {
+	return GetSignStringOfMap(StructToMapStrStr(r))
+}
+
+// GetSignStringOfMap joins the sorted non-empty params as k1=v1&k2=v2, ignore Sign in params
+func GetSignStringOfMap(params map[string]string) string {
 	var buf bytes.Buffer
 
-	params := StructToMapStrStr(r)
 	signParamKeys := make([]string, 0, len(params))
 	for k, v := range params {
 		if k != "Sign" && v != "" {
@@ -110,6 +110,10 @@ func (o *ViperX) parseStruct(fs *pflag.FlagSet, rt reflect.Type, tagName string,
 	for i := 0; i < rt.NumField(); i++ {
 		t := rt.Field(i)
 		fieldName := parseTypeName(t, tagName)
+		if fieldName == "-" {
+			// ignored by mapstructure as well, e.g. func fields
+			continue
+		}
 
 		switch t.Type.Kind() {
 		case reflect.Struct: // Handle nested struct